@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
@@ -17,6 +20,7 @@ import (
 	"github.com/enleur/shrink/internal/storage"
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
@@ -51,14 +55,50 @@ func main() {
 		}
 	}()
 
-	redis, err := storage.NewRedisStore(conf.Redis.Address, conf.Redis.DB)
+	store, err := storage.Open(conf)
 	if err != nil {
-		logger.Fatal("failed to init redis store", zap.Error(err))
+		logger.Fatal("failed to init store", zap.Error(err))
 	}
-	defer func() { _ = redis.Close() }()
+	defer func() { _ = store.Close() }()
 
-	short := shortener.NewService(redis)
-	server := api.NewServer(logger, short)
+	if collector, ok := store.(prometheus.Collector); ok {
+		if err := prometheus.Register(collector); err != nil {
+			logger.Fatal("failed to register store metrics", zap.Error(err))
+		}
+	}
+
+	var links shortener.Store = store
+	if conf.Cache.Enabled {
+		links = storage.NewCachedStore(store, conf.Cache.Size, conf.Cache.TTL, conf.Cache.NegativeTTL)
+	}
+
+	codes, err := initCodeGenerator(conf.Shortener, store)
+	if err != nil {
+		logger.Fatal("failed to init code generator", zap.Error(err))
+	}
+
+	short := shortener.NewService(links,
+		shortener.WithMaxAttempts(conf.Shortener.MaxAttempts),
+		shortener.WithCodeGenerator(codes),
+		shortener.WithDefaultTTL(conf.Shortener.DefaultTTL),
+		shortener.WithMaxTTL(conf.Shortener.MaxTTL),
+		shortener.WithSlidingExpiration(conf.Shortener.SlidingTTL, conf.Shortener.SlidingMaxTTL),
+		shortener.WithHistorySize(conf.Shortener.HistorySize),
+		shortener.WithPasswordAttempts(conf.Shortener.PasswordAttempts, conf.Shortener.PasswordLockout),
+		shortener.WithBatchLimit(conf.Shortener.BatchLimit),
+	)
+	if !slices.Contains(shortener.RedirectStatuses, conf.Server.RedirectStatus) {
+		logger.Fatal("invalid redirect status", zap.Int("status", conf.Server.RedirectStatus))
+	}
+	proxies, err := parseTrustedProxies(conf.Server.TrustedProxies)
+	if err != nil {
+		logger.Fatal("invalid trusted proxies", zap.Error(err))
+	}
+	server := api.NewServer(logger, short,
+		api.WithNotActiveResponse(conf.Server.NotActiveStatus, conf.Server.NotActiveRedirect),
+		api.WithRedirectStatus(conf.Server.RedirectStatus),
+		api.WithAdmins(conf.Server.AdminUsers...),
+		api.WithTrustedProxies(proxies...))
 
 	router := setupRouter(logger, server)
 
@@ -80,6 +120,42 @@ func initLogger(mode string) *zap.Logger {
 	return zap.Must(zap.NewDevelopment())
 }
 
+// parseTrustedProxies reads proxy addresses, each a single IP or a CIDR
+// range.
+func parseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func initCodeGenerator(conf config.ShortenerConfig, counter shortener.Counter) (shortener.CodeGenerator, error) {
+	switch conf.CodeStrategy {
+	case shortener.CodeStrategyRandom:
+		return shortener.NewRandomGenerator(conf.CodeAlphabet, conf.CodeLength)
+	case shortener.CodeStrategyCounter:
+		return shortener.NewCounterGenerator(counter, conf.CodeAlphabet)
+	case shortener.CodeStrategyFeistel:
+		return shortener.NewFeistelGenerator(counter, conf.CodeAlphabet, conf.CodeLength, []byte(conf.FeistelKey))
+	default:
+		return nil, fmt.Errorf("unknown code strategy %q", conf.CodeStrategy)
+	}
+}
+
 func initTracer(conf config.OtelConfig) (*sdktrace.TracerProvider, error) {
 	exporter, err := otlptrace.New(
 		context.Background(),
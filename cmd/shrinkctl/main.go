@@ -0,0 +1,147 @@
+// Command shrinkctl runs maintenance tasks against the configured link store.
+//
+//	shrinkctl export [-o links.jsonl]
+//	shrinkctl import [-i links.jsonl] [-on-conflict skip|overwrite|fail]
+//	shrinkctl upgrade
+//
+// The store is selected with the same environment variables as the server.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/enleur/shrink/internal/backup"
+	"github.com/enleur/shrink/internal/config"
+	"github.com/enleur/shrink/internal/storage"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(ctx, os.Args[2:])
+	case "import":
+		err = runImport(ctx, os.Args[2:])
+	case "upgrade":
+		err = runUpgrade(ctx)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "shrinkctl:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: shrinkctl export [-o file] | import [-i file] [-on-conflict skip|overwrite|fail] | upgrade")
+}
+
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("o", "-", "output file, - for stdout")
+	_ = fs.Parse(args)
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		w = f
+	}
+
+	n, err := backup.Export(ctx, store, w)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d links\n", n)
+	return nil
+}
+
+func runImport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	input := fs.String("i", "-", "input file, - for stdin")
+	onConflict := fs.String("on-conflict", string(backup.ConflictFail), "what to do with existing codes: skip, overwrite or fail")
+	_ = fs.Parse(args)
+
+	policy, err := backup.ParseConflictPolicy(*onConflict)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	stats, err := backup.Import(ctx, store, r, policy)
+	fmt.Fprintf(os.Stderr, "imported %d, overwritten %d, skipped %d, expired %d\n",
+		stats.Imported, stats.Overwritten, stats.Skipped, stats.Expired)
+	return err
+}
+
+// runUpgrade rewrites links stored in an older layout. Only Redis has one;
+// SQL migrations run whenever the store is opened.
+func runUpgrade(ctx context.Context) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = store.Close() }()
+
+	upgrader, ok := store.(interface {
+		UpgradeLinks(ctx context.Context) (int, error)
+	})
+	if !ok {
+		fmt.Fprintln(os.Stderr, "nothing to upgrade")
+		return nil
+	}
+
+	n, err := upgrader.UpgradeLinks(ctx)
+	fmt.Fprintf(os.Stderr, "upgraded %d links\n", n)
+	return err
+}
+
+func openStore() (storage.Backend, error) {
+	conf, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(conf)
+}
@@ -2,25 +2,90 @@ package api
 
 import (
 	"errors"
+	"io"
+	"math"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/enleur/shrink/internal/shortener"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// retryAfter is advertised to clients when the store is unavailable.
+const retryAfter = 5 * time.Second
+
 type Server struct {
 	logger *zap.Logger
 	short  shortener.Shortener
+
+	notActiveStatus   int
+	notActiveRedirect string
+	redirectStatus    int
+	admins            []string
+	trustedProxies    []netip.Prefix
+}
+
+type Option func(*Server)
+
+// WithNotActiveResponse sets how GetShortCode and PostShortCode answer for
+// links whose activation window hasn't opened yet: a redirect to
+// redirectURL if it is set, otherwise an error with the given status. The
+// default is 404.
+func WithNotActiveResponse(status int, redirectURL string) Option {
+	return func(s *Server) {
+		if status > 0 {
+			s.notActiveStatus = status
+		}
+		s.notActiveRedirect = redirectURL
+	}
 }
 
-func NewServer(logger *zap.Logger, short shortener.Shortener) *Server {
-	return &Server{
-		logger: logger,
-		short:  short,
+// WithRedirectStatus sets the status GetShortCode redirects with for links
+// that don't carry their own. The default is 302.
+func WithRedirectStatus(status int) Option {
+	return func(s *Server) {
+		if status > 0 {
+			s.redirectStatus = status
+		}
 	}
 }
 
+// WithAdmins sets the users, as named by the X-Forwarded-User header, that
+// GET /links lets list every stored link and that may change any link. The
+// header is only believed on requests from a proxy set with
+// WithTrustedProxies.
+func WithAdmins(users ...string) Option {
+	return func(s *Server) {
+		s.admins = users
+	}
+}
+
+// WithTrustedProxies sets the addresses of the authenticating proxies whose
+// X-Forwarded-User header is trusted to name the user. Without any, nobody
+// may list every stored link or change a link.
+func WithTrustedProxies(proxies ...netip.Prefix) Option {
+	return func(s *Server) {
+		s.trustedProxies = proxies
+	}
+}
+
+func NewServer(logger *zap.Logger, short shortener.Shortener, opts ...Option) *Server {
+	s := &Server{
+		logger:          logger,
+		short:           short,
+		notActiveStatus: http.StatusNotFound,
+		redirectStatus:  http.StatusFound,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
 func (s *Server) PostShorten(ctx *gin.Context) {
 	var req PostShortenJSONRequestBody
 	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
@@ -29,28 +94,700 @@ func (s *Server) PostShorten(ctx *gin.Context) {
 		return
 	}
 
-	url, err := s.short.ShortenURL(ctx.Request.Context(), *req.Url)
+	var opts shortener.ShortenOptions
+	if req.Alias != nil {
+		opts.Alias = *req.Alias
+	}
+	if req.ForceNew != nil {
+		opts.ForceNew = *req.ForceNew
+	}
+	if req.Ttl != nil {
+		ttl, err := time.ParseDuration(*req.Ttl)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ttl: " + err.Error()})
+			return
+		}
+		opts.TTL = ttl
+	}
+	if req.ExpiresAt != nil {
+		opts.ExpiresAt = *req.ExpiresAt
+	}
+	if req.Permanent != nil {
+		opts.Permanent = *req.Permanent
+	}
+	if req.MaxClicks != nil {
+		opts.MaxClicks = *req.MaxClicks
+	}
+	if req.NotBefore != nil {
+		opts.NotBefore = *req.NotBefore
+	}
+	if req.NotAfter != nil {
+		opts.NotAfter = *req.NotAfter
+	}
+	if req.Sliding != nil {
+		opts.Sliding = *req.Sliding
+	}
+	if req.Password != nil {
+		opts.Password = *req.Password
+	}
+	if req.Title != nil {
+		opts.Title = *req.Title
+	}
+	if req.Description != nil {
+		opts.Description = *req.Description
+	}
+	if req.Tags != nil {
+		opts.Tags = *req.Tags
+	}
+	if req.RedirectType != nil {
+		opts.RedirectStatus = int(*req.RedirectType)
+	}
+	opts.Creator = ctx.GetHeader("X-Forwarded-User")
+
+	link, err := s.short.ShortenURL(ctx.Request.Context(), *req.Url, opts)
 	if err != nil {
-		var invalidURLErr shortener.InvalidURLError
-		if errors.As(err, &invalidURLErr) {
+		switch shortener.ErrorKind(err) {
+		case shortener.KindInvalid:
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		} else {
+		case shortener.KindConflict:
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case shortener.KindUnavailable:
+			s.logger.Error("Store unavailable while shortening URL", zap.Error(err))
+			s.serviceUnavailable(ctx)
+		default:
 			s.logger.Error("Failed to shorten URL", zap.Error(err))
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		}
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"shortUrl": url})
+	resp := gin.H{"shortUrl": link.Code}
+	if !link.ExpiresAt.IsZero() {
+		resp["expiresAt"] = link.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	ctx.JSON(http.StatusOK, resp)
 
 }
 
-func (s *Server) GetShortCode(ctx *gin.Context, shortCode string) {
-	url, err := s.short.GetLongURL(ctx.Request.Context(), shortCode)
+func (s *Server) PostShortenBatch(ctx *gin.Context) {
+	var req PostShortenBatchJSONRequestBody
+	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+		s.logger.Info("failed to parse body", zap.Error(err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	creator := ctx.GetHeader("X-Forwarded-User")
+	results := make([]gin.H, len(req.Items))
+	items := make([]shortener.BatchItem, 0, len(req.Items))
+	indexes := make([]int, 0, len(req.Items))
+	for i, reqItem := range req.Items {
+		item := shortener.BatchItem{Opts: shortener.ShortenOptions{Creator: creator}}
+		if reqItem.Url != nil {
+			item.URL = *reqItem.Url
+		}
+		if reqItem.Alias != nil {
+			item.Opts.Alias = *reqItem.Alias
+		}
+		results[i] = gin.H{"url": item.URL}
+		if reqItem.Ttl != nil {
+			ttl, err := time.ParseDuration(*reqItem.Ttl)
+			if err != nil {
+				results[i]["status"] = http.StatusBadRequest
+				results[i]["error"] = "invalid ttl: " + err.Error()
+				continue
+			}
+			item.Opts.TTL = ttl
+		}
+		items = append(items, item)
+		indexes = append(indexes, i)
+	}
+
+	batch, err := s.short.ShortenBatch(ctx.Request.Context(), items)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{})
+		switch shortener.ErrorKind(err) {
+		case shortener.KindInvalid:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case shortener.KindUnavailable:
+			s.logger.Error("Store unavailable while shortening batch", zap.Error(err))
+			s.serviceUnavailable(ctx)
+		default:
+			s.logger.Error("Failed to shorten batch", zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
 		return
 	}
 
-	ctx.Redirect(http.StatusFound, url)
+	for j, result := range batch {
+		resp := results[indexes[j]]
+		if result.Err == nil {
+			resp["status"] = http.StatusOK
+			resp["shortUrl"] = result.Code
+			if !result.ExpiresAt.IsZero() {
+				resp["expiresAt"] = result.ExpiresAt.UTC().Format(time.RFC3339)
+			}
+			continue
+		}
+		switch shortener.ErrorKind(result.Err) {
+		case shortener.KindInvalid:
+			resp["status"] = http.StatusBadRequest
+			resp["error"] = result.Err.Error()
+		case shortener.KindConflict:
+			resp["status"] = http.StatusConflict
+			resp["error"] = result.Err.Error()
+		case shortener.KindUnavailable:
+			s.logger.Error("Store unavailable while shortening batch item", zap.String("url", items[j].URL), zap.Error(result.Err))
+			resp["status"] = http.StatusServiceUnavailable
+			resp["error"] = "Service temporarily unavailable"
+		default:
+			s.logger.Error("Failed to shorten batch item", zap.String("url", items[j].URL), zap.Error(result.Err))
+			resp["status"] = http.StatusInternalServerError
+			resp["error"] = "Internal server error"
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func (s *Server) GetLinksCode(ctx *gin.Context, code string) {
+	info, err := s.short.GetLink(ctx.Request.Context(), code)
+	if err != nil {
+		switch shortener.ErrorKind(err) {
+		case shortener.KindNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		case shortener.KindUnavailable:
+			s.logger.Error("Store unavailable while reading link", zap.String("shortCode", code), zap.Error(err))
+			s.serviceUnavailable(ctx)
+		default:
+			s.logger.Error("Failed to read link", zap.String("shortCode", code), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	ctx.Header("ETag", info.ETag)
+	ctx.JSON(http.StatusOK, linkResponse(info))
+}
+
+// isAdmin reports whether the request comes through a trusted proxy on
+// behalf of one of the admins.
+func (s *Server) isAdmin(ctx *gin.Context) bool {
+	user := s.trustedUser(ctx)
+	return user != "" && slices.Contains(s.admins, user)
+}
+
+// trustedUser returns the user a trusted proxy authenticated the request
+// for, or "" if there is none. Anyone can set X-Forwarded-User, so it only
+// counts when the proxy that authenticated the user sent it.
+func (s *Server) trustedUser(ctx *gin.Context) string {
+	user := ctx.GetHeader("X-Forwarded-User")
+	if user == "" {
+		return ""
+	}
+	addr, err := netip.ParseAddrPort(ctx.Request.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	trusted := slices.ContainsFunc(s.trustedProxies, func(proxy netip.Prefix) bool {
+		return proxy.Contains(addr.Addr().Unmap())
+	})
+	if !trusted {
+		return ""
+	}
+	return user
+}
+
+// mayChange reports whether the caller may change, renew or delete the link
+// at code, and answers the request itself when not. Admins may change any
+// link, and other users the links they created, as named by a trusted
+// X-Forwarded-User.
+func (s *Server) mayChange(ctx *gin.Context, code string) bool {
+	if s.isAdmin(ctx) {
+		return true
+	}
+	user := s.trustedUser(ctx)
+	if user == "" {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Only admins and the link's creator may change it"})
+		return false
+	}
+
+	info, err := s.short.GetLink(ctx.Request.Context(), code)
+	if err != nil {
+		switch shortener.ErrorKind(err) {
+		case shortener.KindNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		case shortener.KindUnavailable:
+			s.logger.Error("Store unavailable while reading link", zap.String("shortCode", code), zap.Error(err))
+			s.serviceUnavailable(ctx)
+		default:
+			s.logger.Error("Failed to read link", zap.String("shortCode", code), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return false
+	}
+	if info.Creator != user {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Only admins and the link's creator may change it"})
+		return false
+	}
+	return true
+}
+
+// linkResponse describes a link as GET /links/{code} and GET /links do.
+func linkResponse(info shortener.LinkInfo) gin.H {
+	resp := gin.H{
+		"shortUrl": info.Code,
+		"clicks":   info.Clicks,
+		"status":   info.Status,
+	}
+	if !info.Protected {
+		resp["url"] = info.URL
+	}
+	if !info.CreatedAt.IsZero() {
+		resp["createdAt"] = info.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	if !info.ExpiresAt.IsZero() {
+		resp["expiresAt"] = info.ExpiresAt.UTC().Format(time.RFC3339)
+		resp["ttl"] = info.TTL.Round(time.Second).String()
+	}
+	if info.Slide > 0 {
+		resp["slide"] = info.Slide.String()
+		resp["maxExpiresAt"] = info.MaxExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if info.MaxClicks > 0 {
+		resp["maxClicks"] = info.MaxClicks
+	}
+	if info.Creator != "" {
+		resp["creator"] = info.Creator
+	}
+	if info.Protected {
+		resp["protected"] = true
+	}
+	if info.Title != "" {
+		resp["title"] = info.Title
+	}
+	if info.Description != "" {
+		resp["description"] = info.Description
+	}
+	if len(info.Tags) > 0 {
+		resp["tags"] = info.Tags
+	}
+	if info.RedirectStatus != 0 {
+		resp["redirectType"] = info.RedirectStatus
+	}
+	return resp
+}
+
+// GetLinks searches links when given a filter. Without one it lists every
+// stored link with ScanLinks instead. Only admins may look through every
+// link; anyone else may only list the links they created themselves, since
+// even a broad filter such as ?q=http would otherwise enumerate them all.
+func (s *Server) GetLinks(ctx *gin.Context, params GetLinksParams) {
+	var filter shortener.LinkFilter
+	if params.Tag != nil {
+		filter.Tag = *params.Tag
+	}
+	if params.Domain != nil {
+		filter.Domain = *params.Domain
+	}
+	if params.Creator != nil {
+		filter.Creator = *params.Creator
+	}
+	if params.CreatedFrom != nil {
+		filter.CreatedFrom = *params.CreatedFrom
+	}
+	if params.CreatedTo != nil {
+		filter.CreatedTo = *params.CreatedTo
+	}
+	if params.Q != nil {
+		filter.Text = *params.Q
+	}
+	var cursor string
+	if params.Cursor != nil {
+		cursor = *params.Cursor
+	}
+	var limit int
+	if params.Limit != nil {
+		if *params.Limit < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be positive"})
+			return
+		}
+		limit = *params.Limit
+	}
+
+	if !s.isAdmin(ctx) && (filter.Creator == "" || filter.Creator != s.trustedUser(ctx)) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "Only admins may list links other than their own; filter by your own creator instead"})
+		return
+	}
+
+	var page shortener.LinkPage
+	var err error
+	if filter == (shortener.LinkFilter{}) {
+		page, err = s.short.ScanLinks(ctx.Request.Context(), cursor, limit)
+	} else {
+		page, err = s.short.ListLinks(ctx.Request.Context(), filter, cursor, limit)
+	}
+	if err != nil {
+		switch shortener.ErrorKind(err) {
+		case shortener.KindInvalid:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case shortener.KindUnavailable:
+			s.logger.Error("Store unavailable while listing links", zap.Error(err))
+			s.serviceUnavailable(ctx)
+		default:
+			s.logger.Error("Failed to list links", zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	links := make([]gin.H, len(page.Links))
+	for i, info := range page.Links {
+		links[i] = linkResponse(info)
+	}
+	resp := gin.H{"links": links}
+	if page.Cursor != "" {
+		resp["nextCursor"] = page.Cursor
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) PatchLinksCode(ctx *gin.Context, code string, params PatchLinksCodeParams) {
+	var req PatchLinksCodeJSONRequestBody
+	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+		s.logger.Info("failed to parse body", zap.Error(err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var opts shortener.UpdateOptions
+	if req.Url != nil {
+		if *req.Url == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "url must not be empty"})
+			return
+		}
+		opts.URL = *req.Url
+	}
+	if req.Ttl != nil {
+		ttl, err := time.ParseDuration(*req.Ttl)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ttl: " + err.Error()})
+			return
+		}
+		opts.TTL = ttl
+	}
+	if req.ExpiresAt != nil {
+		opts.ExpiresAt = *req.ExpiresAt
+	}
+	if req.Permanent != nil {
+		opts.Permanent = *req.Permanent
+	}
+	if req.Reason != nil {
+		opts.Reason = *req.Reason
+	}
+	if req.RedirectType != nil {
+		status := int(*req.RedirectType)
+		opts.RedirectStatus = &status
+	}
+	if params.IfMatch != nil {
+		opts.IfMatch = *params.IfMatch
+	}
+	if !s.mayChange(ctx, code) {
+		return
+	}
+	opts.Actor = s.trustedUser(ctx)
+
+	link, err := s.short.UpdateLink(ctx.Request.Context(), code, opts)
+	if err != nil {
+		switch shortener.ErrorKind(err) {
+		case shortener.KindInvalid:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case shortener.KindNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		case shortener.KindExpired, shortener.KindDisabled:
+			ctx.JSON(http.StatusGone, gin.H{"error": "Short URL is no longer available"})
+		case shortener.KindPrecondition:
+			ctx.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		case shortener.KindUnavailable:
+			s.logger.Error("Store unavailable while updating link", zap.String("shortCode", code), zap.Error(err))
+			s.serviceUnavailable(ctx)
+		default:
+			s.logger.Error("Failed to update link", zap.String("shortCode", code), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	resp := gin.H{"shortUrl": code, "url": link.URL}
+	if !link.ExpiresAt.IsZero() {
+		resp["expiresAt"] = link.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if link.RedirectStatus != 0 {
+		resp["redirectType"] = link.RedirectStatus
+	}
+	ctx.Header("ETag", link.ETag)
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) GetLinksCodeHistory(ctx *gin.Context, code string) {
+	history, err := s.short.LinkHistory(ctx.Request.Context(), code)
+	if err != nil {
+		switch shortener.ErrorKind(err) {
+		case shortener.KindNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		case shortener.KindUnavailable:
+			s.logger.Error("Store unavailable while reading link history", zap.String("shortCode", code), zap.Error(err))
+			s.serviceUnavailable(ctx)
+		default:
+			s.logger.Error("Failed to read link history", zap.String("shortCode", code), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	entries := make([]gin.H, len(history))
+	for i, entry := range history {
+		entries[i] = gin.H{
+			"version":   entry.Version,
+			"changedAt": entry.ChangedAt.UTC().Format(time.RFC3339),
+		}
+		if entry.URL != "" {
+			entries[i]["url"] = entry.URL
+		}
+		if entry.Actor != "" {
+			entries[i]["actor"] = entry.Actor
+		}
+		if entry.Reason != "" {
+			entries[i]["reason"] = entry.Reason
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"shortUrl": code, "history": entries})
+}
+
+func (s *Server) PostLinksCodeRollback(ctx *gin.Context, code string, params PostLinksCodeRollbackParams) {
+	var req PostLinksCodeRollbackJSONRequestBody
+	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.logger.Info("failed to parse body", zap.Error(err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := shortener.RollbackOptions{Version: req.Version}
+	if req.Reason != nil {
+		opts.Reason = *req.Reason
+	}
+	if params.IfMatch != nil {
+		opts.IfMatch = *params.IfMatch
+	}
+	if !s.mayChange(ctx, code) {
+		return
+	}
+	opts.Actor = s.trustedUser(ctx)
+
+	link, err := s.short.RollbackLink(ctx.Request.Context(), code, opts)
+	if err != nil {
+		switch shortener.ErrorKind(err) {
+		case shortener.KindInvalid:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case shortener.KindNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		case shortener.KindExpired, shortener.KindDisabled:
+			ctx.JSON(http.StatusGone, gin.H{"error": "Short URL is no longer available"})
+		case shortener.KindPrecondition:
+			ctx.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		case shortener.KindUnavailable:
+			s.logger.Error("Store unavailable while rolling back link", zap.String("shortCode", code), zap.Error(err))
+			s.serviceUnavailable(ctx)
+		default:
+			s.logger.Error("Failed to roll back link", zap.String("shortCode", code), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	s.logger.Info("Link rolled back", zap.String("shortCode", code), zap.String("url", link.URL))
+	resp := gin.H{"shortUrl": code, "url": link.URL}
+	if !link.ExpiresAt.IsZero() {
+		resp["expiresAt"] = link.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	ctx.Header("ETag", link.ETag)
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) PostLinksCodeRenew(ctx *gin.Context, code string) {
+	var req PostLinksCodeRenewJSONRequestBody
+	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.logger.Info("failed to parse body", zap.Error(err))
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var opts shortener.RenewOptions
+	if req.Ttl != nil {
+		ttl, err := time.ParseDuration(*req.Ttl)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid ttl: " + err.Error()})
+			return
+		}
+		opts.TTL = ttl
+	}
+	if !s.mayChange(ctx, code) {
+		return
+	}
+
+	link, err := s.short.RenewLink(ctx.Request.Context(), code, opts)
+	if err != nil {
+		switch shortener.ErrorKind(err) {
+		case shortener.KindInvalid:
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case shortener.KindNotFound:
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		case shortener.KindExpired, shortener.KindDisabled:
+			ctx.JSON(http.StatusGone, gin.H{"error": "Short URL is no longer available"})
+		case shortener.KindUnavailable:
+			s.logger.Error("Store unavailable while renewing link", zap.String("shortCode", code), zap.Error(err))
+			s.serviceUnavailable(ctx)
+		default:
+			s.logger.Error("Failed to renew link", zap.String("shortCode", code), zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	resp := gin.H{"shortUrl": code, "url": link.URL}
+	if !link.ExpiresAt.IsZero() {
+		resp["expiresAt"] = link.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) DeleteLinksCode(ctx *gin.Context, code string) {
+	if !s.mayChange(ctx, code) {
+		return
+	}
+
+	err := s.short.DeleteLink(ctx.Request.Context(), code)
+	switch shortener.ErrorKind(err) {
+	case "":
+		s.logger.Info("Link deleted", zap.String("shortCode", code))
+		ctx.Status(http.StatusNoContent)
+		ctx.Writer.WriteHeaderNow()
+	case shortener.KindNotFound:
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+	case shortener.KindUnavailable:
+		s.logger.Error("Store unavailable while deleting link", zap.String("shortCode", code), zap.Error(err))
+		s.serviceUnavailable(ctx)
+	default:
+		s.logger.Error("Failed to delete link", zap.String("shortCode", code), zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+func (s *Server) GetShortCode(ctx *gin.Context, shortCode string) {
+	redirect, err := s.short.FollowLink(ctx.Request.Context(), shortCode)
+
+	kind := shortener.ErrorKind(err)
+	switch kind {
+	case "":
+		redirectsTotal.WithLabelValues("redirected").Inc()
+		status := redirect.Status
+		if status == 0 {
+			status = s.redirectStatus
+		}
+		ctx.Redirect(status, redirect.URL)
+	case shortener.KindNotFound:
+		redirectsTotal.WithLabelValues(kind).Inc()
+		s.logger.Info("Short code not found", zap.String("shortCode", shortCode))
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+	case shortener.KindNotActive:
+		redirectsTotal.WithLabelValues(kind).Inc()
+		s.logger.Info("Short code not active yet", zap.String("shortCode", shortCode))
+		s.notActive(ctx, http.StatusFound)
+	case shortener.KindPassword:
+		redirectsTotal.WithLabelValues(kind).Inc()
+		s.renderPasswordForm(ctx, http.StatusOK, shortCode, "")
+	case shortener.KindExpired, shortener.KindExhausted, shortener.KindDisabled:
+		redirectsTotal.WithLabelValues(kind).Inc()
+		s.logger.Info("Short code no longer available", zap.String("shortCode", shortCode), zap.String("reason", kind))
+		ctx.JSON(http.StatusGone, gin.H{"error": "Short URL is no longer available"})
+	case shortener.KindUnavailable:
+		redirectsTotal.WithLabelValues(kind).Inc()
+		s.logger.Error("Store unavailable while resolving short code", zap.String("shortCode", shortCode), zap.Error(err))
+		s.serviceUnavailable(ctx)
+	default:
+		redirectsTotal.WithLabelValues(shortener.KindInternal).Inc()
+		s.logger.Error("Failed to resolve short code", zap.String("shortCode", shortCode), zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+// PostShortCode takes the password form served for a protected link and
+// redirects with 303, so the browser follows it with a GET. The link's own
+// redirect type is ignored here: a 307 or 308 would post the password on to
+// the destination.
+func (s *Server) PostShortCode(ctx *gin.Context, shortCode string) {
+	var req PostShortCodeFormdataRequestBody
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var password string
+	if req.Password != nil {
+		password = *req.Password
+	}
+
+	redirect, err := s.short.UnlockLink(ctx.Request.Context(), shortCode, password)
+
+	kind := shortener.ErrorKind(err)
+	switch kind {
+	case "":
+		redirectsTotal.WithLabelValues("redirected").Inc()
+		ctx.Redirect(http.StatusSeeOther, redirect.URL)
+		ctx.Writer.WriteHeaderNow()
+	case shortener.KindUnauthorized:
+		s.logger.Info("Incorrect password for short code", zap.String("shortCode", shortCode))
+		s.renderPasswordForm(ctx, http.StatusUnauthorized, shortCode, "Incorrect password, try again.")
+	case shortener.KindThrottled:
+		s.logger.Warn("Short code locked after incorrect passwords", zap.String("shortCode", shortCode))
+		wait := retryAfter
+		var throttled shortener.TooManyAttemptsError
+		if errors.As(err, &throttled) {
+			wait = throttled.RetryAfter
+		}
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many incorrect passwords, try again later"})
+	case shortener.KindNotFound:
+		redirectsTotal.WithLabelValues(kind).Inc()
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+	case shortener.KindNotActive:
+		redirectsTotal.WithLabelValues(kind).Inc()
+		s.notActive(ctx, http.StatusSeeOther)
+		ctx.Writer.WriteHeaderNow()
+	case shortener.KindExpired, shortener.KindExhausted, shortener.KindDisabled:
+		redirectsTotal.WithLabelValues(kind).Inc()
+		ctx.JSON(http.StatusGone, gin.H{"error": "Short URL is no longer available"})
+	case shortener.KindUnavailable:
+		redirectsTotal.WithLabelValues(kind).Inc()
+		s.logger.Error("Store unavailable while unlocking short code", zap.String("shortCode", shortCode), zap.Error(err))
+		s.serviceUnavailable(ctx)
+	default:
+		redirectsTotal.WithLabelValues(shortener.KindInternal).Inc()
+		s.logger.Error("Failed to unlock short code", zap.String("shortCode", shortCode), zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+// notActive answers for a link whose activation window hasn't opened yet as
+// WithNotActiveResponse configured, redirecting with redirectStatus if it
+// names a page.
+func (s *Server) notActive(ctx *gin.Context, redirectStatus int) {
+	if s.notActiveRedirect != "" {
+		ctx.Redirect(redirectStatus, s.notActiveRedirect)
+		return
+	}
+	ctx.JSON(s.notActiveStatus, gin.H{"error": "Short URL is not active yet"})
+}
+
+func (s *Server) serviceUnavailable(ctx *gin.Context) {
+	ctx.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable"})
 }
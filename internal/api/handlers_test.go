@@ -4,10 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/enleur/shrink/internal/shortener"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -18,14 +25,75 @@ type MockShortener struct {
 	mock.Mock
 }
 
-func (m *MockShortener) ShortenURL(ctx context.Context, longURL string) (string, error) {
-	args := m.Called(ctx, longURL)
-	return args.String(0), args.Error(1)
+// forwardedBy makes r come through a proxy in 10.0.0.0/8 on behalf of user;
+// an empty user leaves r anonymous.
+func forwardedBy(r *http.Request, user string) {
+	r.RemoteAddr = "10.1.2.3:40000"
+	if user != "" {
+		r.Header.Set("X-Forwarded-User", user)
+	}
 }
 
-func (m *MockShortener) GetLongURL(ctx context.Context, shortCode string) (string, error) {
+func (m *MockShortener) ShortenURL(ctx context.Context, longURL string, opts shortener.ShortenOptions) (shortener.ShortenResult, error) {
+	args := m.Called(ctx, longURL, opts)
+	return args.Get(0).(shortener.ShortenResult), args.Error(1)
+}
+
+func (m *MockShortener) ShortenBatch(ctx context.Context, items []shortener.BatchItem) ([]shortener.BatchResult, error) {
+	args := m.Called(ctx, items)
+	results, _ := args.Get(0).([]shortener.BatchResult)
+	return results, args.Error(1)
+}
+
+func (m *MockShortener) FollowLink(ctx context.Context, shortCode string) (shortener.Redirect, error) {
+	args := m.Called(ctx, shortCode)
+	return args.Get(0).(shortener.Redirect), args.Error(1)
+}
+
+func (m *MockShortener) UnlockLink(ctx context.Context, shortCode, password string) (shortener.Redirect, error) {
+	args := m.Called(ctx, shortCode, password)
+	return args.Get(0).(shortener.Redirect), args.Error(1)
+}
+
+func (m *MockShortener) GetLink(ctx context.Context, shortCode string) (shortener.LinkInfo, error) {
+	args := m.Called(ctx, shortCode)
+	return args.Get(0).(shortener.LinkInfo), args.Error(1)
+}
+
+func (m *MockShortener) ListLinks(ctx context.Context, filter shortener.LinkFilter, cursor string, limit int) (shortener.LinkPage, error) {
+	args := m.Called(ctx, filter, cursor, limit)
+	return args.Get(0).(shortener.LinkPage), args.Error(1)
+}
+
+func (m *MockShortener) ScanLinks(ctx context.Context, cursor string, limit int) (shortener.LinkPage, error) {
+	args := m.Called(ctx, cursor, limit)
+	return args.Get(0).(shortener.LinkPage), args.Error(1)
+}
+
+func (m *MockShortener) UpdateLink(ctx context.Context, shortCode string, opts shortener.UpdateOptions) (shortener.UpdateResult, error) {
+	args := m.Called(ctx, shortCode, opts)
+	return args.Get(0).(shortener.UpdateResult), args.Error(1)
+}
+
+func (m *MockShortener) LinkHistory(ctx context.Context, shortCode string) ([]shortener.HistoryEntry, error) {
+	args := m.Called(ctx, shortCode)
+	history, _ := args.Get(0).([]shortener.HistoryEntry)
+	return history, args.Error(1)
+}
+
+func (m *MockShortener) RollbackLink(ctx context.Context, shortCode string, opts shortener.RollbackOptions) (shortener.UpdateResult, error) {
+	args := m.Called(ctx, shortCode, opts)
+	return args.Get(0).(shortener.UpdateResult), args.Error(1)
+}
+
+func (m *MockShortener) RenewLink(ctx context.Context, shortCode string, opts shortener.RenewOptions) (shortener.UpdateResult, error) {
+	args := m.Called(ctx, shortCode, opts)
+	return args.Get(0).(shortener.UpdateResult), args.Error(1)
+}
+
+func (m *MockShortener) DeleteLink(ctx context.Context, shortCode string) error {
 	args := m.Called(ctx, shortCode)
-	return args.String(0), args.Error(1)
+	return args.Error(0)
 }
 
 func TestPostShorten(t *testing.T) {
@@ -36,7 +104,7 @@ func TestPostShorten(t *testing.T) {
 	server := NewServer(logger, mockShortener)
 
 	t.Run("Successful Shortening", func(t *testing.T) {
-		mockShortener.On("ShortenURL", mock.Anything, "https://example.com").Return("abc123", nil)
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com", shortener.ShortenOptions{}).Return(shortener.ShortenResult{Code: "abc123"}, nil)
 
 		w := httptest.NewRecorder()
 		c, _ := gin.CreateTestContext(w)
@@ -51,4 +119,959 @@ func TestPostShorten(t *testing.T) {
 		_ = json.Unmarshal(w.Body.Bytes(), &response)
 		assert.Equal(t, "abc123", response.ShortUrl)
 	})
+
+	t.Run("Alias", func(t *testing.T) {
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/launch", shortener.ShortenOptions{Alias: "launch2026"}).Return(shortener.ShortenResult{Code: "launch2026"}, nil)
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/taken", shortener.ShortenOptions{Alias: "taken"}).Return(shortener.ShortenResult{}, shortener.AliasTakenError{Alias: "taken"})
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/bad", shortener.ShortenOptions{Alias: "metrics"}).Return(shortener.ShortenResult{}, shortener.InvalidAliasError{Alias: "metrics", Reason: "is reserved"})
+
+		tests := []struct {
+			name       string
+			body       string
+			wantStatus int
+		}{
+			{name: "Claimed", body: `{"url":"https://example.com/launch","alias":"launch2026"}`, wantStatus: http.StatusOK},
+			{name: "Taken", body: `{"url":"https://example.com/taken","alias":"taken"}`, wantStatus: http.StatusConflict},
+			{name: "Invalid", body: `{"url":"https://example.com/bad","alias":"metrics"}`, wantStatus: http.StatusBadRequest},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request, _ = http.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(tt.body))
+
+				server.PostShorten(c)
+
+				assert.Equal(t, tt.wantStatus, w.Code)
+			})
+		}
+	})
+	t.Run("Code Space Exhausted", func(t *testing.T) {
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/crowded", shortener.ShortenOptions{}).
+			Return(shortener.ShortenResult{}, fmt.Errorf("no free short code after 5 attempts: %w", shortener.ErrCodeSpaceExhausted))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(`{"url":"https://example.com/crowded"}`))
+
+		server.PostShorten(c)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"error":"Internal server error"}`, w.Body.String())
+	})
+	t.Run("Metadata", func(t *testing.T) {
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/guide", shortener.ShortenOptions{
+			Title: "Guide", Description: "Getting started", Tags: []string{"docs", "Onboarding"},
+		}).Return(shortener.ShortenResult{Code: "guide"}, nil)
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/tagged", shortener.ShortenOptions{Tags: []string{"no spaces"}}).
+			Return(shortener.ShortenResult{}, shortener.InvalidMetadataError{Reason: "bad tag"})
+
+		tests := []struct {
+			name       string
+			body       string
+			wantStatus int
+		}{
+			{
+				name:       "Stored",
+				body:       `{"url":"https://example.com/guide","title":"Guide","description":"Getting started","tags":["docs","Onboarding"]}`,
+				wantStatus: http.StatusOK,
+			},
+			{name: "Invalid Tag", body: `{"url":"https://example.com/tagged","tags":["no spaces"]}`, wantStatus: http.StatusBadRequest},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request, _ = http.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(tt.body))
+
+				server.PostShorten(c)
+
+				assert.Equal(t, tt.wantStatus, w.Code)
+			})
+		}
+	})
+	t.Run("Redirect Type", func(t *testing.T) {
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/hook", shortener.ShortenOptions{RedirectStatus: http.StatusTemporaryRedirect}).
+			Return(shortener.ShortenResult{Code: "hook"}, nil)
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/hook", shortener.ShortenOptions{RedirectStatus: http.StatusOK}).
+			Return(shortener.ShortenResult{}, shortener.InvalidRedirectError{Status: http.StatusOK})
+
+		tests := []struct {
+			name       string
+			body       string
+			wantStatus int
+		}{
+			{name: "Stored", body: `{"url":"https://example.com/hook","redirectType":307}`, wantStatus: http.StatusOK},
+			{name: "Not A Redirect", body: `{"url":"https://example.com/hook","redirectType":200}`, wantStatus: http.StatusBadRequest},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request, _ = http.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(tt.body))
+
+				server.PostShorten(c)
+
+				assert.Equal(t, tt.wantStatus, w.Code)
+			})
+		}
+	})
+	t.Run("Records Creator", func(t *testing.T) {
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/mine", shortener.ShortenOptions{Creator: "alice"}).Return(shortener.ShortenResult{Code: "mine"}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(`{"url":"https://example.com/mine"}`))
+		c.Request.Header.Set("X-Forwarded-User", "alice")
+
+		server.PostShorten(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/ttl", shortener.ShortenOptions{TTL: 90 * time.Minute}).Return(shortener.ShortenResult{Code: "ttl123", ExpiresAt: expiresAt}, nil)
+		mockShortener.On("ShortenURL", mock.Anything, "https://example.com/forever", shortener.ShortenOptions{Permanent: true}).Return(shortener.ShortenResult{Code: "forever"}, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(`{"url":"https://example.com/ttl","ttl":"90m"}`))
+		server.PostShorten(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"shortUrl":"ttl123","expiresAt":"2030-01-02T03:04:05Z"}`, w.Body.String())
+
+		w = httptest.NewRecorder()
+		c, _ = gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(`{"url":"https://example.com/forever","permanent":true}`))
+		server.PostShorten(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"shortUrl":"forever"}`, w.Body.String())
+
+		w = httptest.NewRecorder()
+		c, _ = gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/shorten", bytes.NewBufferString(`{"url":"https://example.com","ttl":"soon"}`))
+		server.PostShorten(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestPostShortenBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockShortener := new(MockShortener)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, mockShortener)
+
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockShortener.On("ShortenBatch", mock.Anything, []shortener.BatchItem{
+		{URL: "https://example.com/a", Opts: shortener.ShortenOptions{TTL: time.Hour, Creator: "alice"}},
+		{URL: "https://example.com/b", Opts: shortener.ShortenOptions{Alias: "taken", Creator: "alice"}},
+		{URL: "https://example.com/c", Opts: shortener.ShortenOptions{Creator: "alice"}},
+		{URL: "https://example.com/d", Opts: shortener.ShortenOptions{Creator: "alice"}},
+	}).Return([]shortener.BatchResult{
+		{ShortenResult: shortener.ShortenResult{Code: "abc123", ExpiresAt: expiresAt}},
+		{Err: shortener.AliasTakenError{Alias: "taken"}},
+		{Err: shortener.StorageError{Op: "create", Err: errors.New("connection refused")}},
+		{Err: fmt.Errorf("no free short code after 5 attempts: %w", shortener.ErrCodeSpaceExhausted)},
+	}, nil)
+	mockShortener.On("ShortenBatch", mock.Anything, []shortener.BatchItem{{URL: "https://example.com/down"}}).
+		Return(nil, shortener.StorageError{Op: "find_by_urls", Err: errors.New("connection refused")})
+	mockShortener.On("ShortenBatch", mock.Anything, mock.Anything).
+		Return(nil, shortener.BatchTooLargeError{Limit: 2})
+
+	t.Run("Per Item Results", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/shorten/batch", bytes.NewBufferString(`{"items":[
+			{"url":"https://example.com/a","ttl":"1h"},
+			{"url":"https://example.com/b","alias":"taken"},
+			{"url":"https://example.com/bad","ttl":"soon"},
+			{"url":"https://example.com/c"},
+			{"url":"https://example.com/d"}]}`))
+		c.Request.Header.Set("X-Forwarded-User", "alice")
+
+		server.PostShortenBatch(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"results":[
+			{"url":"https://example.com/a","status":200,"shortUrl":"abc123","expiresAt":"2030-01-02T03:04:05Z"},
+			{"url":"https://example.com/b","status":409,"error":"alias \"taken\" is already taken"},
+			{"url":"https://example.com/bad","status":400,"error":"invalid ttl: time: invalid duration \"soon\""},
+			{"url":"https://example.com/c","status":503,"error":"Service temporarily unavailable"},
+			{"url":"https://example.com/d","status":500,"error":"Internal server error"}]}`, w.Body.String())
+	})
+
+	t.Run("Too Many Items", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/shorten/batch",
+			bytes.NewBufferString(`{"items":[{"url":"https://example.com/a"},{"url":"https://example.com/b"},{"url":"https://example.com/c"}]}`))
+
+		server.PostShortenBatch(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Store Unavailable", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/shorten/batch", bytes.NewBufferString(`{"items":[{"url":"https://example.com/down"}]}`))
+
+		server.PostShortenBatch(c)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Invalid Body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/shorten/batch", bytes.NewBufferString(`{"items":"https://example.com"}`))
+
+		server.PostShortenBatch(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestGetLinksCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockShortener := new(MockShortener)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, mockShortener)
+
+	mockShortener.On("GetLink", mock.Anything, "abc123").Return(shortener.LinkInfo{
+		Code:           "abc123",
+		URL:            "https://example.com",
+		CreatedAt:      time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExpiresAt:      time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+		TTL:            90*time.Minute + 300*time.Millisecond,
+		Slide:          24 * time.Hour,
+		MaxExpiresAt:   time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC),
+		Clicks:         7,
+		MaxClicks:      10,
+		Creator:        "alice",
+		Protected:      true,
+		Title:          "Example",
+		Description:    "An example link",
+		Tags:           []string{"docs", "q3"},
+		Status:         shortener.StatusActive,
+		ETag:           `"v1"`,
+		RedirectStatus: http.StatusMovedPermanently,
+	}, nil)
+	mockShortener.On("GetLink", mock.Anything, "forever").Return(shortener.LinkInfo{
+		Code:   "forever",
+		URL:    "https://example.com/forever",
+		Status: shortener.StatusDisabled,
+		ETag:   `"v2"`,
+	}, nil)
+	mockShortener.On("GetLink", mock.Anything, "missing").Return(shortener.LinkInfo{}, fmt.Errorf("get: %w", shortener.ErrNotFound))
+	mockShortener.On("GetLink", mock.Anything, "down").Return(shortener.LinkInfo{}, shortener.StorageError{Op: "get", Err: errors.New("connection refused")})
+
+	tests := []struct {
+		name       string
+		code       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Details",
+			code:       "abc123",
+			wantStatus: http.StatusOK,
+			wantBody: `{"shortUrl":"abc123","createdAt":"2030-01-01T00:00:00Z",
+				"expiresAt":"2030-01-02T00:00:00Z","ttl":"1h30m0s","slide":"24h0m0s","maxExpiresAt":"2030-01-31T00:00:00Z","clicks":7,"maxClicks":10,"creator":"alice",
+				"protected":true,"title":"Example","description":"An example link","tags":["docs","q3"],"redirectType":301,
+				"status":"active"}`,
+		},
+		{
+			name:       "Optional Fields Omitted",
+			code:       "forever",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"shortUrl":"forever","url":"https://example.com/forever","clicks":0,"status":"disabled"}`,
+		},
+		{name: "Not Found", code: "missing", wantStatus: http.StatusNotFound},
+		{name: "Store Unavailable", code: "down", wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest(http.MethodGet, "/links/"+tt.code, nil)
+
+			server.GetLinksCode(c, tt.code)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+				assert.NotEmpty(t, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestGetLinks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockShortener := new(MockShortener)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, mockShortener, WithAdmins("root"), WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+
+	from := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tag, none, domain, text, creator, cursor, badCursor, down := "docs", "none", "example.com", "guide", "alice", "YWJjMTIz", "!", "down"
+	scanCursor, anything, epoch := "MDo0Mg", ":", time.Unix(0, 0).UTC()
+	one, zero := 1, 0
+	mockShortener.On("ListLinks", mock.Anything, shortener.LinkFilter{Tag: "none"}, "", 0).Return(shortener.LinkPage{}, nil)
+	mockShortener.On("ScanLinks", mock.Anything, "", 0).Return(shortener.LinkPage{
+		Links: []shortener.LinkInfo{{
+			Code:      "xyz789",
+			URL:       "https://example.net",
+			ExpiresAt: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+			TTL:       2 * time.Hour,
+			Status:    shortener.StatusActive,
+		}},
+		Cursor: "MDo0Mg",
+	}, nil)
+	mockShortener.On("ScanLinks", mock.Anything, "MDo0Mg", 0).Return(shortener.LinkPage{}, nil)
+	mockShortener.On("ListLinks", mock.Anything, shortener.LinkFilter{Tag: "docs", Domain: "example.com", CreatedFrom: from, Text: "guide"}, "", 1).Return(shortener.LinkPage{
+		Links: []shortener.LinkInfo{{
+			Code:   "abc123",
+			URL:    "https://example.com/guide",
+			Tags:   []string{"docs"},
+			Status: shortener.StatusActive,
+		}},
+		Cursor: "YWJjMTIz",
+	}, nil)
+	mockShortener.On("ListLinks", mock.Anything, shortener.LinkFilter{Creator: "alice"}, "YWJjMTIz", 0).Return(shortener.LinkPage{
+		Links: []shortener.LinkInfo{{Code: "def456", URL: "https://example.org", Creator: "alice", Status: shortener.StatusActive}},
+	}, nil)
+	mockShortener.On("ListLinks", mock.Anything, shortener.LinkFilter{Tag: "none"}, "!", 0).Return(shortener.LinkPage{}, shortener.InvalidFilterError{Reason: "malformed cursor"})
+	mockShortener.On("ListLinks", mock.Anything, shortener.LinkFilter{Tag: "down"}, "", 0).Return(shortener.LinkPage{}, shortener.StorageError{Op: "search", Err: errors.New("connection refused")})
+
+	tests := []struct {
+		name       string
+		params     GetLinksParams
+		user       string
+		remoteAddr string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Empty",
+			params:     GetLinksParams{Tag: &none},
+			user:       "root",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"links":[]}`,
+		},
+		{
+			name: "Filtered Page",
+			params: GetLinksParams{
+				Tag: &tag, Domain: &domain, CreatedFrom: &from, Q: &text, Limit: &one,
+			},
+			user:       "root",
+			wantStatus: http.StatusOK,
+			wantBody: `{"links":[{"shortUrl":"abc123","url":"https://example.com/guide","clicks":0,"tags":["docs"],"status":"active"}],
+				"nextCursor":"YWJjMTIz"}`,
+		},
+		{
+			name:       "Last Page",
+			params:     GetLinksParams{Creator: &creator, Cursor: &cursor},
+			user:       "alice",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"links":[{"shortUrl":"def456","url":"https://example.org","clicks":0,"creator":"alice","status":"active"}]}`,
+		},
+		{
+			name:       "List All",
+			user:       "root",
+			wantStatus: http.StatusOK,
+			wantBody: `{"links":[{"shortUrl":"xyz789","url":"https://example.net","expiresAt":"2030-01-02T00:00:00Z","ttl":"2h0m0s",
+				"clicks":0,"status":"active"}],"nextCursor":"MDo0Mg"}`,
+		},
+		{
+			name:       "List All Last Page",
+			params:     GetLinksParams{Cursor: &scanCursor},
+			user:       "root",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"links":[]}`,
+		},
+		{name: "List All Without Admin", user: "alice", wantStatus: http.StatusForbidden},
+		{name: "List All Anonymously", wantStatus: http.StatusForbidden},
+		{name: "List All Bypassing Proxy", user: "root", remoteAddr: "203.0.113.7:51234", wantStatus: http.StatusForbidden},
+		{name: "Invalid Limit", params: GetLinksParams{Limit: &zero}, wantStatus: http.StatusBadRequest},
+		{name: "Search All Without Admin", params: GetLinksParams{Q: &anything}, user: "alice", wantStatus: http.StatusForbidden},
+		{name: "Search Since Epoch Anonymously", params: GetLinksParams{CreatedFrom: &epoch}, wantStatus: http.StatusForbidden},
+		{name: "Search Others' Links", params: GetLinksParams{Creator: &creator}, user: "bob", wantStatus: http.StatusForbidden},
+		{name: "Own Links Bypassing Proxy", params: GetLinksParams{Creator: &creator}, user: "alice", remoteAddr: "203.0.113.7:51234", wantStatus: http.StatusForbidden},
+		{name: "Invalid Cursor", params: GetLinksParams{Tag: &none, Cursor: &badCursor}, user: "root", wantStatus: http.StatusBadRequest},
+		{name: "Store Unavailable", params: GetLinksParams{Tag: &down}, user: "root", wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest(http.MethodGet, "/links", nil)
+			c.Request.RemoteAddr = "10.1.2.3:40000"
+			if tt.remoteAddr != "" {
+				c.Request.RemoteAddr = tt.remoteAddr
+			}
+			if tt.user != "" {
+				c.Request.Header.Set("X-Forwarded-User", tt.user)
+			}
+
+			server.GetLinks(c, tt.params)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPatchLinksCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockShortener := new(MockShortener)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, mockShortener, WithAdmins("root"), WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockShortener.On("GetLink", mock.Anything, "audited").Return(shortener.LinkInfo{Code: "audited", Creator: "alice"}, nil)
+	mockShortener.On("UpdateLink", mock.Anything, "abc123", shortener.UpdateOptions{URL: "https://example.com/new", IfMatch: `"v1"`, Actor: "root"}).
+		Return(shortener.UpdateResult{URL: "https://example.com/new", ExpiresAt: expiresAt, ETag: `"v2"`}, nil)
+	mockShortener.On("UpdateLink", mock.Anything, "abc123", shortener.UpdateOptions{URL: "https://example.com/new", IfMatch: `"v0"`, Actor: "root"}).
+		Return(shortener.UpdateResult{}, fmt.Errorf("update: %w", shortener.ErrPreconditionFailed))
+	mockShortener.On("UpdateLink", mock.Anything, "missing", shortener.UpdateOptions{Permanent: true, Actor: "root"}).
+		Return(shortener.UpdateResult{}, fmt.Errorf("update: %w", shortener.ErrNotFound))
+	mockShortener.On("UpdateLink", mock.Anything, "audited", shortener.UpdateOptions{URL: "https://example.com/new", Actor: "alice", Reason: "typo"}).
+		Return(shortener.UpdateResult{URL: "https://example.com/new", ETag: `"v3"`}, nil)
+	permanent, standard := http.StatusMovedPermanently, 0
+	mockShortener.On("UpdateLink", mock.Anything, "seo", shortener.UpdateOptions{RedirectStatus: &permanent, Actor: "root"}).
+		Return(shortener.UpdateResult{URL: "https://example.com/page", RedirectStatus: permanent, ETag: `"v4"`}, nil)
+	mockShortener.On("UpdateLink", mock.Anything, "seo", shortener.UpdateOptions{RedirectStatus: &standard, Actor: "root"}).
+		Return(shortener.UpdateResult{URL: "https://example.com/page", ETag: `"v5"`}, nil)
+
+	patch := func(code, ifMatch, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPatch, "/links/"+code, bytes.NewBufferString(body))
+		forwardedBy(c.Request, "root")
+		var params PatchLinksCodeParams
+		if ifMatch != "" {
+			params.IfMatch = &ifMatch
+		}
+		server.PatchLinksCode(c, code, params)
+		return w
+	}
+
+	t.Run("Updated", func(t *testing.T) {
+		w := patch("abc123", `"v1"`, `{"url":"https://example.com/new"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"v2"`, w.Header().Get("ETag"))
+		assert.JSONEq(t, `{"shortUrl":"abc123","url":"https://example.com/new","expiresAt":"2030-01-02T03:04:05Z"}`, w.Body.String())
+	})
+
+	t.Run("Stale ETag", func(t *testing.T) {
+		w := patch("abc123", `"v0"`, `{"url":"https://example.com/new"}`)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		w := patch("missing", "", `{"permanent":true}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	patchAs := func(user, remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPatch, "/links/audited", bytes.NewBufferString(`{"url":"https://example.com/new","reason":"typo"}`))
+		forwardedBy(c.Request, user)
+		if remoteAddr != "" {
+			c.Request.RemoteAddr = remoteAddr
+		}
+		server.PatchLinksCode(c, "audited", PatchLinksCodeParams{})
+		return w
+	}
+
+	t.Run("Creator Records Actor and Reason", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, patchAs("alice", "").Code)
+	})
+
+	t.Run("Forbidden", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, patchAs("", "").Code)
+		assert.Equal(t, http.StatusForbidden, patchAs("bob", "").Code)
+		assert.Equal(t, http.StatusForbidden, patchAs("alice", "203.0.113.7:51234").Code)
+	})
+
+	t.Run("Redirect Type", func(t *testing.T) {
+		w := patch("seo", "", `{"redirectType":301}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"shortUrl":"seo","url":"https://example.com/page","redirectType":301}`, w.Body.String())
+
+		w = patch("seo", "", `{"redirectType":0}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"shortUrl":"seo","url":"https://example.com/page"}`, w.Body.String())
+	})
+
+	t.Run("Invalid Body", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, patch("abc123", "", `{"url":""}`).Code)
+		assert.Equal(t, http.StatusBadRequest, patch("abc123", "", `{"ttl":"soon"}`).Code)
+	})
+}
+
+func TestGetLinksCodeHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockShortener := new(MockShortener)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, mockShortener)
+
+	changedAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockShortener.On("LinkHistory", mock.Anything, "abc123").Return([]shortener.HistoryEntry{
+		{Version: 0, URL: "https://example.com/first", Actor: "alice", ChangedAt: changedAt, Reason: "typo"},
+		{Version: 1, URL: "https://example.com/second", ChangedAt: changedAt.Add(time.Hour)},
+	}, nil)
+	mockShortener.On("LinkHistory", mock.Anything, "fresh").Return(nil, nil)
+	mockShortener.On("LinkHistory", mock.Anything, "missing").Return(nil, fmt.Errorf("history: %w", shortener.ErrNotFound))
+
+	get := func(code string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/links/"+code+"/history", nil)
+		server.GetLinksCodeHistory(c, code)
+		return w
+	}
+
+	t.Run("History", func(t *testing.T) {
+		w := get("abc123")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"shortUrl":"abc123","history":[
+			{"version":0,"url":"https://example.com/first","actor":"alice","changedAt":"2030-01-02T03:04:05Z","reason":"typo"},
+			{"version":1,"url":"https://example.com/second","changedAt":"2030-01-02T04:04:05Z"}
+		]}`, w.Body.String())
+	})
+
+	t.Run("Never Changed", func(t *testing.T) {
+		w := get("fresh")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"shortUrl":"fresh","history":[]}`, w.Body.String())
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("missing").Code)
+	})
+}
+
+func TestPostLinksCodeRollback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockShortener := new(MockShortener)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, mockShortener, WithAdmins("root"), WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+
+	version, unknown := int64(1), int64(7)
+	mockShortener.On("GetLink", mock.Anything, "abc123").Return(shortener.LinkInfo{Code: "abc123", Creator: "alice"}, nil)
+	mockShortener.On("RollbackLink", mock.Anything, "abc123", shortener.RollbackOptions{Actor: "root"}).
+		Return(shortener.UpdateResult{URL: "https://example.com/first", ETag: `"v3"`}, nil)
+	mockShortener.On("RollbackLink", mock.Anything, "abc123", shortener.RollbackOptions{Version: &version, Reason: "bad deploy", IfMatch: `"v2"`, Actor: "alice"}).
+		Return(shortener.UpdateResult{URL: "https://example.com/second", ETag: `"v3"`}, nil)
+	mockShortener.On("RollbackLink", mock.Anything, "abc123", shortener.RollbackOptions{Version: &unknown, Actor: "root"}).
+		Return(shortener.UpdateResult{}, fmt.Errorf("rollback: %w", shortener.UnknownVersionError{Version: 7}))
+	mockShortener.On("RollbackLink", mock.Anything, "abc123", shortener.RollbackOptions{IfMatch: `"v0"`, Actor: "root"}).
+		Return(shortener.UpdateResult{}, fmt.Errorf("rollback: %w", shortener.ErrPreconditionFailed))
+	mockShortener.On("RollbackLink", mock.Anything, "deleted", shortener.RollbackOptions{Actor: "root"}).
+		Return(shortener.UpdateResult{}, fmt.Errorf("rollback: %w", shortener.ErrDisabled))
+
+	rollback := func(code, ifMatch, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/links/"+code+"/rollback", bytes.NewBufferString(body))
+		forwardedBy(c.Request, "root")
+		var params PostLinksCodeRollbackParams
+		if ifMatch != "" {
+			params.IfMatch = &ifMatch
+		}
+		server.PostLinksCodeRollback(c, code, params)
+		return w
+	}
+
+	t.Run("Undo Last Change", func(t *testing.T) {
+		w := rollback("abc123", "", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"v3"`, w.Header().Get("ETag"))
+		assert.JSONEq(t, `{"shortUrl":"abc123","url":"https://example.com/first"}`, w.Body.String())
+	})
+
+	t.Run("Chosen Version", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/links/abc123/rollback", bytes.NewBufferString(`{"version":1,"reason":"bad deploy"}`))
+		forwardedBy(c.Request, "alice")
+		ifMatch := `"v2"`
+
+		server.PostLinksCodeRollback(c, "abc123", PostLinksCodeRollbackParams{IfMatch: &ifMatch})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"shortUrl":"abc123","url":"https://example.com/second"}`, w.Body.String())
+	})
+
+	t.Run("Unknown Version", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, rollback("abc123", "", `{"version":7}`).Code)
+	})
+
+	t.Run("Stale ETag", func(t *testing.T) {
+		assert.Equal(t, http.StatusPreconditionFailed, rollback("abc123", `"v0"`, "").Code)
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		assert.Equal(t, http.StatusGone, rollback("deleted", "", "").Code)
+	})
+
+	t.Run("Not Creator", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/links/abc123/rollback", http.NoBody)
+		forwardedBy(c.Request, "bob")
+
+		server.PostLinksCodeRollback(c, "abc123", PostLinksCodeRollbackParams{})
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("Invalid Body", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, rollback("abc123", "", `{"version":"latest"}`).Code)
+	})
+}
+
+func TestPostLinksCodeRenew(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockShortener := new(MockShortener)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, mockShortener, WithAdmins("root"), WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockShortener.On("GetLink", mock.Anything, "abc123").Return(shortener.LinkInfo{Code: "abc123", Creator: "alice"}, nil)
+	mockShortener.On("GetLink", mock.Anything, "missing").Return(shortener.LinkInfo{}, fmt.Errorf("get: %w", shortener.ErrNotFound))
+	mockShortener.On("RenewLink", mock.Anything, "abc123", shortener.RenewOptions{}).
+		Return(shortener.UpdateResult{URL: "https://example.com", ExpiresAt: expiresAt}, nil)
+	mockShortener.On("RenewLink", mock.Anything, "abc123", shortener.RenewOptions{TTL: 72 * time.Hour}).
+		Return(shortener.UpdateResult{URL: "https://example.com", ExpiresAt: expiresAt.Add(48 * time.Hour)}, nil)
+	mockShortener.On("RenewLink", mock.Anything, "abc123", shortener.RenewOptions{TTL: 9000 * time.Hour}).
+		Return(shortener.UpdateResult{}, shortener.InvalidExpirationError{Reason: "links may live at most 720h0m0s"})
+	mockShortener.On("RenewLink", mock.Anything, "missing", shortener.RenewOptions{}).
+		Return(shortener.UpdateResult{}, fmt.Errorf("renew: %w", shortener.ErrNotFound))
+	mockShortener.On("RenewLink", mock.Anything, "gone", shortener.RenewOptions{}).
+		Return(shortener.UpdateResult{}, fmt.Errorf("renew: %w", shortener.ErrExpired))
+
+	tests := []struct {
+		name       string
+		code       string
+		body       string
+		user       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Default Lifetime",
+			code:       "abc123",
+			user:       "root",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"shortUrl":"abc123","url":"https://example.com","expiresAt":"2030-01-02T03:04:05Z"}`,
+		},
+		{
+			name:       "Requested Lifetime",
+			code:       "abc123",
+			body:       `{"ttl":"72h"}`,
+			user:       "root",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"shortUrl":"abc123","url":"https://example.com","expiresAt":"2030-01-04T03:04:05Z"}`,
+		},
+		{name: "Too Long", code: "abc123", body: `{"ttl":"9000h"}`, user: "root", wantStatus: http.StatusBadRequest},
+		{name: "Invalid TTL", code: "abc123", body: `{"ttl":"soon"}`, user: "root", wantStatus: http.StatusBadRequest},
+		{name: "Not Found", code: "missing", user: "root", wantStatus: http.StatusNotFound},
+		{name: "Expired", code: "gone", user: "root", wantStatus: http.StatusGone},
+		{name: "Creator", code: "abc123", user: "alice", wantStatus: http.StatusOK},
+		{name: "Not Creator", code: "abc123", user: "bob", wantStatus: http.StatusForbidden},
+		{name: "Anonymous", code: "abc123", wantStatus: http.StatusForbidden},
+		{name: "Missing For Non-Admin", code: "missing", user: "bob", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest(http.MethodPost, "/links/"+tt.code+"/renew", bytes.NewBufferString(tt.body))
+			forwardedBy(c.Request, tt.user)
+
+			server.PostLinksCodeRenew(c, tt.code)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeleteLinksCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockShortener := new(MockShortener)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, mockShortener, WithAdmins("root"), WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+
+	mockShortener.On("GetLink", mock.Anything, "abc123").Return(shortener.LinkInfo{Code: "abc123", Creator: "alice"}, nil)
+	mockShortener.On("DeleteLink", mock.Anything, "abc123").Return(nil)
+	mockShortener.On("DeleteLink", mock.Anything, "missing").Return(fmt.Errorf("delete: %w", shortener.ErrNotFound))
+	mockShortener.On("DeleteLink", mock.Anything, "down").Return(shortener.StorageError{Op: "delete", Err: errors.New("connection refused")})
+
+	tests := []struct {
+		name       string
+		shortCode  string
+		user       string
+		wantStatus int
+	}{
+		{name: "Deleted", shortCode: "abc123", user: "root", wantStatus: http.StatusNoContent},
+		{name: "Deleted By Creator", shortCode: "abc123", user: "alice", wantStatus: http.StatusNoContent},
+		{name: "Not Creator", shortCode: "abc123", user: "bob", wantStatus: http.StatusForbidden},
+		{name: "Anonymous", shortCode: "abc123", wantStatus: http.StatusForbidden},
+		{name: "Not Found", shortCode: "missing", user: "root", wantStatus: http.StatusNotFound},
+		{name: "Store Unavailable", shortCode: "down", user: "root", wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest(http.MethodDelete, "/links/"+tt.shortCode, nil)
+			forwardedBy(c.Request, tt.user)
+
+			server.DeleteLinksCode(c, tt.shortCode)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestGetShortCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockShortener := new(MockShortener)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, mockShortener)
+
+	mockShortener.On("FollowLink", mock.Anything, "found").Return(shortener.Redirect{URL: "https://example.com"}, nil)
+	mockShortener.On("FollowLink", mock.Anything, "api").
+		Return(shortener.Redirect{URL: "https://example.com/hook", Status: http.StatusPermanentRedirect}, nil)
+	mockShortener.On("FollowLink", mock.Anything, "missing").Return(shortener.Redirect{}, fmt.Errorf("lookup: %w", shortener.ErrNotFound))
+	mockShortener.On("FollowLink", mock.Anything, "expired").Return(shortener.Redirect{}, fmt.Errorf("lookup: %w", shortener.ErrExpired))
+	mockShortener.On("FollowLink", mock.Anything, "used").Return(shortener.Redirect{}, fmt.Errorf("lookup: %w", shortener.ErrExhausted))
+	mockShortener.On("FollowLink", mock.Anything, "deleted").Return(shortener.Redirect{}, fmt.Errorf("lookup: %w", shortener.ErrDisabled))
+	mockShortener.On("FollowLink", mock.Anything, "soon").Return(shortener.Redirect{}, fmt.Errorf("lookup: %w", shortener.ErrNotYetActive))
+	mockShortener.On("FollowLink", mock.Anything, "down").Return(shortener.Redirect{}, shortener.StorageError{Op: "get", Err: errors.New("connection refused")})
+	mockShortener.On("FollowLink", mock.Anything, "locked").Return(shortener.Redirect{}, fmt.Errorf("lookup: %w", shortener.ErrPasswordRequired))
+
+	tests := []struct {
+		name       string
+		shortCode  string
+		wantStatus int
+	}{
+		{name: "Redirect", shortCode: "found", wantStatus: http.StatusFound},
+		{name: "Link Redirect Type", shortCode: "api", wantStatus: http.StatusPermanentRedirect},
+		{name: "Not Found", shortCode: "missing", wantStatus: http.StatusNotFound},
+		{name: "Expired", shortCode: "expired", wantStatus: http.StatusGone},
+		{name: "Out Of Clicks", shortCode: "used", wantStatus: http.StatusGone},
+		{name: "Not Active Yet", shortCode: "soon", wantStatus: http.StatusNotFound},
+		{name: "Deleted", shortCode: "deleted", wantStatus: http.StatusGone},
+		{name: "Store Unavailable", shortCode: "down", wantStatus: http.StatusServiceUnavailable},
+		{name: "Password Required", shortCode: "locked", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest(http.MethodGet, "/"+tt.shortCode, nil)
+
+			server.GetShortCode(c, tt.shortCode)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	t.Run("Retry-After On Unavailable", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/down", nil)
+
+		server.GetShortCode(c, "down")
+
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Configured Not Active Response", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/soon", nil)
+
+		NewServer(logger, mockShortener, WithNotActiveResponse(http.StatusForbidden, "")).GetShortCode(c, "soon")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = httptest.NewRecorder()
+		c, _ = gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/soon", nil)
+
+		NewServer(logger, mockShortener, WithNotActiveResponse(0, "https://example.com/coming-soon")).GetShortCode(c, "soon")
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://example.com/coming-soon", w.Header().Get("Location"))
+	})
+
+	t.Run("Configured Redirect Status", func(t *testing.T) {
+		permanent := NewServer(logger, mockShortener, WithRedirectStatus(http.StatusMovedPermanently))
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/found", nil)
+
+		permanent.GetShortCode(c, "found")
+		assert.Equal(t, http.StatusMovedPermanently, w.Code)
+		assert.Equal(t, "https://example.com", w.Header().Get("Location"))
+
+		w = httptest.NewRecorder()
+		c, _ = gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/api", nil)
+
+		permanent.GetShortCode(c, "api")
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	})
+}
+
+func TestPostShortCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockShortener := new(MockShortener)
+	logger, _ := zap.NewDevelopment()
+	server := NewServer(logger, mockShortener)
+
+	mockShortener.On("UnlockLink", mock.Anything, "locked", "hunter2").
+		Return(shortener.Redirect{URL: "https://example.com/secret", Status: http.StatusTemporaryRedirect}, nil)
+	mockShortener.On("UnlockLink", mock.Anything, "locked", "guess").Return(shortener.Redirect{}, shortener.ErrIncorrectPassword)
+	mockShortener.On("UnlockLink", mock.Anything, "locked", "again").
+		Return(shortener.Redirect{}, shortener.TooManyAttemptsError{RetryAfter: 90 * time.Second})
+	mockShortener.On("UnlockLink", mock.Anything, "missing", "hunter2").Return(shortener.Redirect{}, fmt.Errorf("unlock: %w", shortener.ErrNotFound))
+	mockShortener.On("UnlockLink", mock.Anything, "used", "hunter2").Return(shortener.Redirect{}, fmt.Errorf("unlock: %w", shortener.ErrExhausted))
+
+	tests := []struct {
+		name       string
+		shortCode  string
+		password   string
+		wantStatus int
+	}{
+		{name: "Correct Password", shortCode: "locked", password: "hunter2", wantStatus: http.StatusSeeOther},
+		{name: "Incorrect Password", shortCode: "locked", password: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "Locked Out", shortCode: "locked", password: "again", wantStatus: http.StatusTooManyRequests},
+		{name: "Not Found", shortCode: "missing", password: "hunter2", wantStatus: http.StatusNotFound},
+		{name: "Out Of Clicks", shortCode: "used", password: "hunter2", wantStatus: http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			form := url.Values{"password": {tt.password}}
+			c.Request, _ = http.NewRequest(http.MethodPost, "/"+tt.shortCode, strings.NewReader(form.Encode()))
+			c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			server.PostShortCode(c, tt.shortCode)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+
+	t.Run("Redirects To Destination", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/locked", strings.NewReader("password=hunter2"))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		server.PostShortCode(c, "locked")
+
+		assert.Equal(t, "https://example.com/secret", w.Header().Get("Location"))
+	})
+
+	t.Run("Form Shown Again", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/locked", strings.NewReader("password=guess"))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		server.PostShortCode(c, "locked")
+
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Contains(t, w.Body.String(), `name="password"`)
+		assert.Contains(t, w.Body.String(), "Incorrect password")
+	})
+
+	t.Run("Retry-After When Locked Out", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/locked", strings.NewReader("password=again"))
+		c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		server.PostShortCode(c, "locked")
+
+		assert.Equal(t, "90", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Not Active Yet", func(t *testing.T) {
+		mockShortener.On("UnlockLink", mock.Anything, "early", "hunter2").Return(shortener.Redirect{}, fmt.Errorf("unlock: %w", shortener.ErrNotYetActive))
+
+		tests := []struct {
+			name         string
+			server       *Server
+			wantStatus   int
+			wantLocation string
+		}{
+			{name: "Default", server: server, wantStatus: http.StatusNotFound},
+			{
+				name:       "Configured Status",
+				server:     NewServer(logger, mockShortener, WithNotActiveResponse(http.StatusForbidden, "")),
+				wantStatus: http.StatusForbidden,
+			},
+			{
+				name:         "Configured Page",
+				server:       NewServer(logger, mockShortener, WithNotActiveResponse(0, "https://example.com/soon")),
+				wantStatus:   http.StatusSeeOther,
+				wantLocation: "https://example.com/soon",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				c, _ := gin.CreateTestContext(w)
+				c.Request, _ = http.NewRequest(http.MethodPost, "/early", strings.NewReader("password=hunter2"))
+				c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+				tt.server.PostShortCode(c, "early")
+
+				assert.Equal(t, tt.wantStatus, w.Code)
+				assert.Equal(t, tt.wantLocation, w.Header().Get("Location"))
+			})
+		}
+	})
 }
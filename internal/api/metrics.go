@@ -0,0 +1,14 @@
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var redirectsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "redirects_total",
+		Help: "Total number of short code lookups by outcome",
+	},
+	[]string{"outcome"},
+)
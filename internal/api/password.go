@@ -0,0 +1,46 @@
+package api
+
+import (
+	"html/template"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// passwordForm asks for the password of a protected link. It posts back to
+// the short URL it was served from.
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body>
+<form method="post" action="{{.Code}}">
+<p>This link is protected. Enter its password to continue.</p>
+{{if .Error}}<p role="alert">{{.Error}}</p>
+{{end}}<input type="password" name="password" autocomplete="current-password" required autofocus>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+type passwordFormData struct {
+	Code  string
+	Error string
+}
+
+// renderPasswordForm writes the password form for code with the given
+// status. The page must not be cached, since the link may be unprotected or
+// gone by the next visit.
+func (s *Server) renderPasswordForm(ctx *gin.Context, status int, code, errMsg string) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Status(status)
+	if err := passwordForm.Execute(ctx.Writer, passwordFormData{Code: code, Error: errMsg}); err != nil {
+		s.logger.Error("Failed to render password form", zap.String("shortCode", code), zap.Error(err))
+	}
+}
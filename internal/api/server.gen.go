@@ -13,12 +13,39 @@ import (
 
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
+	// Search or list links
+	// (GET /links)
+	GetLinks(c *gin.Context, params GetLinksParams)
+	// Take a link down
+	// (DELETE /links/{code})
+	DeleteLinksCode(c *gin.Context, code string)
+	// Inspect a link without following it
+	// (GET /links/{code})
+	GetLinksCode(c *gin.Context, code string)
+	// Change a link's destination, expiration or redirect type
+	// (PATCH /links/{code})
+	PatchLinksCode(c *gin.Context, code string, params PatchLinksCodeParams)
+	// List the destinations a link has been repointed away from
+	// (GET /links/{code}/history)
+	GetLinksCodeHistory(c *gin.Context, code string)
+	// Extend a link's lifetime
+	// (POST /links/{code}/renew)
+	PostLinksCodeRenew(c *gin.Context, code string)
+	// Point a link back at an earlier destination
+	// (POST /links/{code}/rollback)
+	PostLinksCodeRollback(c *gin.Context, code string, params PostLinksCodeRollbackParams)
 	// Shorten a URL
 	// (POST /shorten)
 	PostShorten(c *gin.Context)
+	// Shorten many URLs at once
+	// (POST /shorten/batch)
+	PostShortenBatch(c *gin.Context)
 	// Redirect to original URL
 	// (GET /{shortCode})
 	GetShortCode(c *gin.Context, shortCode string)
+	// Unlock a password-protected link
+	// (POST /{shortCode})
+	PostShortCode(c *gin.Context, shortCode string)
 }
 
 // ServerInterfaceWrapper converts contexts to parameters.
@@ -30,6 +57,280 @@ type ServerInterfaceWrapper struct {
 
 type MiddlewareFunc func(c *gin.Context)
 
+// GetLinks operation middleware
+func (siw *ServerInterfaceWrapper) GetLinks(c *gin.Context) {
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params GetLinksParams
+
+	// ------------- Optional query parameter "tag" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "tag", c.Request.URL.Query(), &params.Tag)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter tag: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "domain" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "domain", c.Request.URL.Query(), &params.Domain)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter domain: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "creator" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "creator", c.Request.URL.Query(), &params.Creator)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter creator: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "createdFrom" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "createdFrom", c.Request.URL.Query(), &params.CreatedFrom)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter createdFrom: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "createdTo" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "createdTo", c.Request.URL.Query(), &params.CreatedTo)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter createdTo: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "q" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "q", c.Request.URL.Query(), &params.Q)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter q: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", c.Request.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter cursor: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", c.Request.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter limit: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetLinks(c, params)
+}
+
+// DeleteLinksCode operation middleware
+func (siw *ServerInterfaceWrapper) DeleteLinksCode(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "code" -------------
+	var code string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "code", c.Param("code"), &code, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter code: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.DeleteLinksCode(c, code)
+}
+
+// GetLinksCode operation middleware
+func (siw *ServerInterfaceWrapper) GetLinksCode(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "code" -------------
+	var code string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "code", c.Param("code"), &code, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter code: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetLinksCode(c, code)
+}
+
+// PatchLinksCode operation middleware
+func (siw *ServerInterfaceWrapper) PatchLinksCode(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "code" -------------
+	var code string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "code", c.Param("code"), &code, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter code: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PatchLinksCodeParams
+
+	headers := c.Request.Header
+
+	// ------------- Optional header parameter "If-Match" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-Match")]; found {
+		var IfMatch string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandler(c, fmt.Errorf("Expected one value for If-Match, got %d", n), http.StatusBadRequest)
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-Match", valueList[0], &IfMatch, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter If-Match: %w", err), http.StatusBadRequest)
+			return
+		}
+
+		params.IfMatch = &IfMatch
+
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PatchLinksCode(c, code, params)
+}
+
+// GetLinksCodeHistory operation middleware
+func (siw *ServerInterfaceWrapper) GetLinksCodeHistory(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "code" -------------
+	var code string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "code", c.Param("code"), &code, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter code: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.GetLinksCodeHistory(c, code)
+}
+
+// PostLinksCodeRenew operation middleware
+func (siw *ServerInterfaceWrapper) PostLinksCodeRenew(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "code" -------------
+	var code string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "code", c.Param("code"), &code, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter code: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostLinksCodeRenew(c, code)
+}
+
+// PostLinksCodeRollback operation middleware
+func (siw *ServerInterfaceWrapper) PostLinksCodeRollback(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "code" -------------
+	var code string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "code", c.Param("code"), &code, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter code: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params PostLinksCodeRollbackParams
+
+	headers := c.Request.Header
+
+	// ------------- Optional header parameter "If-Match" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("If-Match")]; found {
+		var IfMatch string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandler(c, fmt.Errorf("Expected one value for If-Match, got %d", n), http.StatusBadRequest)
+			return
+		}
+
+		err = runtime.BindStyledParameterWithOptions("simple", "If-Match", valueList[0], &IfMatch, runtime.BindStyledParameterOptions{ParamLocation: runtime.ParamLocationHeader, Explode: false, Required: false})
+		if err != nil {
+			siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter If-Match: %w", err), http.StatusBadRequest)
+			return
+		}
+
+		params.IfMatch = &IfMatch
+
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostLinksCodeRollback(c, code, params)
+}
+
 // PostShorten operation middleware
 func (siw *ServerInterfaceWrapper) PostShorten(c *gin.Context) {
 
@@ -43,6 +344,19 @@ func (siw *ServerInterfaceWrapper) PostShorten(c *gin.Context) {
 	siw.Handler.PostShorten(c)
 }
 
+// PostShortenBatch operation middleware
+func (siw *ServerInterfaceWrapper) PostShortenBatch(c *gin.Context) {
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostShortenBatch(c)
+}
+
 // GetShortCode operation middleware
 func (siw *ServerInterfaceWrapper) GetShortCode(c *gin.Context) {
 
@@ -67,6 +381,30 @@ func (siw *ServerInterfaceWrapper) GetShortCode(c *gin.Context) {
 	siw.Handler.GetShortCode(c, shortCode)
 }
 
+// PostShortCode operation middleware
+func (siw *ServerInterfaceWrapper) PostShortCode(c *gin.Context) {
+
+	var err error
+
+	// ------------- Path parameter "shortCode" -------------
+	var shortCode string
+
+	err = runtime.BindStyledParameterWithOptions("simple", "shortCode", c.Param("shortCode"), &shortCode, runtime.BindStyledParameterOptions{Explode: false, Required: true})
+	if err != nil {
+		siw.ErrorHandler(c, fmt.Errorf("Invalid format for parameter shortCode: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	for _, middleware := range siw.HandlerMiddlewares {
+		middleware(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	siw.Handler.PostShortCode(c, shortCode)
+}
+
 // GinServerOptions provides options for the Gin server.
 type GinServerOptions struct {
 	BaseURL      string
@@ -94,6 +432,15 @@ func RegisterHandlersWithOptions(router gin.IRouter, si ServerInterface, options
 		ErrorHandler:       errorHandler,
 	}
 
+	router.GET(options.BaseURL+"/links", wrapper.GetLinks)
+	router.DELETE(options.BaseURL+"/links/:code", wrapper.DeleteLinksCode)
+	router.GET(options.BaseURL+"/links/:code", wrapper.GetLinksCode)
+	router.PATCH(options.BaseURL+"/links/:code", wrapper.PatchLinksCode)
+	router.GET(options.BaseURL+"/links/:code/history", wrapper.GetLinksCodeHistory)
+	router.POST(options.BaseURL+"/links/:code/renew", wrapper.PostLinksCodeRenew)
+	router.POST(options.BaseURL+"/links/:code/rollback", wrapper.PostLinksCodeRollback)
 	router.POST(options.BaseURL+"/shorten", wrapper.PostShorten)
+	router.POST(options.BaseURL+"/shorten/batch", wrapper.PostShortenBatch)
 	router.GET(options.BaseURL+"/:shortCode", wrapper.GetShortCode)
+	router.POST(options.BaseURL+"/:shortCode", wrapper.PostShortCode)
 }
@@ -3,10 +3,179 @@
 // Code generated by github.com/oapi-codegen/oapi-codegen/v2 version v2.3.1-0.20240802201120-fdf32da8560e DO NOT EDIT.
 package api
 
+import (
+	"time"
+)
+
+// Defines values for PatchLinksCodeJSONBodyRedirectType.
+const (
+	PatchLinksCodeJSONBodyRedirectTypeN0   PatchLinksCodeJSONBodyRedirectType = 0
+	PatchLinksCodeJSONBodyRedirectTypeN301 PatchLinksCodeJSONBodyRedirectType = 301
+	PatchLinksCodeJSONBodyRedirectTypeN302 PatchLinksCodeJSONBodyRedirectType = 302
+	PatchLinksCodeJSONBodyRedirectTypeN307 PatchLinksCodeJSONBodyRedirectType = 307
+	PatchLinksCodeJSONBodyRedirectTypeN308 PatchLinksCodeJSONBodyRedirectType = 308
+)
+
+// Defines values for PostShortenJSONBodyRedirectType.
+const (
+	PostShortenJSONBodyRedirectTypeN301 PostShortenJSONBodyRedirectType = 301
+	PostShortenJSONBodyRedirectTypeN302 PostShortenJSONBodyRedirectType = 302
+	PostShortenJSONBodyRedirectTypeN307 PostShortenJSONBodyRedirectType = 307
+	PostShortenJSONBodyRedirectTypeN308 PostShortenJSONBodyRedirectType = 308
+)
+
+// GetLinksParams defines parameters for GetLinks.
+type GetLinksParams struct {
+	Tag *string `form:"tag,omitempty" json:"tag,omitempty"`
+
+	// Domain Destination host, e.g. example.com
+	Domain  *string `form:"domain,omitempty" json:"domain,omitempty"`
+	Creator *string `form:"creator,omitempty" json:"creator,omitempty"`
+
+	// CreatedFrom Only links created at or after this time
+	CreatedFrom *time.Time `form:"createdFrom,omitempty" json:"createdFrom,omitempty"`
+
+	// CreatedTo Only links created before this time
+	CreatedTo *time.Time `form:"createdTo,omitempty" json:"createdTo,omitempty"`
+
+	// Q Text the URL, title or description contains, ignoring case
+	Q *string `form:"q,omitempty" json:"q,omitempty"`
+
+	// Cursor nextCursor of the previous page
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+
+	// Limit Links per page, 50 by default and at most 500
+	Limit *int `form:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// PatchLinksCodeJSONBody defines parameters for PatchLinksCode.
+type PatchLinksCodeJSONBody struct {
+	// ExpiresAt New expiration time
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// Permanent Make the link never expire
+	Permanent *bool `json:"permanent,omitempty"`
+
+	// Reason Why the destination changed, kept in the link's history
+	Reason *string `json:"reason,omitempty"`
+
+	// RedirectType New status the link redirects with; 0 goes back to the server's default
+	RedirectType *PatchLinksCodeJSONBodyRedirectType `json:"redirectType,omitempty"`
+
+	// Ttl New lifetime from now as a duration, e.g. 72h or 90m
+	Ttl *string `json:"ttl,omitempty"`
+
+	// Url New destination
+	Url *string `json:"url,omitempty"`
+}
+
+// PatchLinksCodeParams defines parameters for PatchLinksCode.
+type PatchLinksCodeParams struct {
+	// IfMatch ETag of the link as last seen; the update is refused if the link has changed since
+	IfMatch *string `json:"If-Match,omitempty"`
+}
+
+// PatchLinksCodeJSONBodyRedirectType defines parameters for PatchLinksCode.
+type PatchLinksCodeJSONBodyRedirectType int
+
+// PostLinksCodeRenewJSONBody defines parameters for PostLinksCodeRenew.
+type PostLinksCodeRenewJSONBody struct {
+	// Ttl Lifetime from now as a duration, e.g. 72h; defaults to the link's slide, or the server's default lifetime
+	Ttl *string `json:"ttl,omitempty"`
+}
+
+// PostLinksCodeRollbackJSONBody defines parameters for PostLinksCodeRollback.
+type PostLinksCodeRollbackJSONBody struct {
+	// Reason Why the link is rolled back, kept in the history
+	Reason *string `json:"reason,omitempty"`
+
+	// Version Version from the history to restore; defaults to the latest entry, undoing the last change
+	Version *int64 `json:"version,omitempty"`
+}
+
+// PostLinksCodeRollbackParams defines parameters for PostLinksCodeRollback.
+type PostLinksCodeRollbackParams struct {
+	// IfMatch ETag of the link as last seen; the rollback is refused if the link has changed since
+	IfMatch *string `json:"If-Match,omitempty"`
+}
+
 // PostShortenJSONBody defines parameters for PostShorten.
 type PostShortenJSONBody struct {
+	// Alias Custom short code, 3 to 64 letters, digits, '-' or '_'
+	Alias       *string `json:"alias,omitempty"`
+	Description *string `json:"description,omitempty"`
+
+	// ExpiresAt Time at which the link expires
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+
+	// ForceNew Create a new code even if a live link to the same URL exists
+	ForceNew *bool `json:"forceNew,omitempty"`
+
+	// MaxClicks Number of redirects the link serves before it is gone
+	MaxClicks *int64 `json:"maxClicks,omitempty"`
+
+	// NotAfter Time the link stops redirecting
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+
+	// NotBefore Time the link starts redirecting
+	NotBefore *time.Time `json:"notBefore,omitempty"`
+
+	// Password Password visitors must enter before they are redirected; stored hashed
+	Password *string `json:"password,omitempty"`
+
+	// Permanent Create a link that never expires
+	Permanent *bool `json:"permanent,omitempty"`
+
+	// RedirectType Status the link redirects with; the server's default when omitted
+	RedirectType *PostShortenJSONBodyRedirectType `json:"redirectType,omitempty"`
+
+	// Sliding Push the expiration out with every redirect, up to a ceiling set by the server
+	Sliding *bool `json:"sliding,omitempty"`
+
+	// Tags Up to 10 tags of letters, digits, '-', '_' or '.', stored lowercase
+	Tags  *[]string `json:"tags,omitempty"`
+	Title *string   `json:"title,omitempty"`
+
+	// Ttl Lifetime of the link as a duration, e.g. 72h or 90m
+	Ttl *string `json:"ttl,omitempty"`
 	Url *string `json:"url,omitempty"`
 }
 
+// PostShortenJSONBodyRedirectType defines parameters for PostShorten.
+type PostShortenJSONBodyRedirectType int
+
+// PostShortenBatchJSONBody defines parameters for PostShortenBatch.
+type PostShortenBatchJSONBody struct {
+	// Items At most as many items as the server's batch limit
+	Items []struct {
+		// Alias Custom short code, 3 to 64 letters, digits, '-' or '_'
+		Alias *string `json:"alias,omitempty"`
+
+		// Ttl Lifetime of the link as a duration, e.g. 72h or 90m
+		Ttl *string `json:"ttl,omitempty"`
+		Url *string `json:"url,omitempty"`
+	} `json:"items"`
+}
+
+// PostShortCodeFormdataBody defines parameters for PostShortCode.
+type PostShortCodeFormdataBody struct {
+	Password *string `form:"password,omitempty" json:"password,omitempty"`
+}
+
+// PatchLinksCodeJSONRequestBody defines body for PatchLinksCode for application/json ContentType.
+type PatchLinksCodeJSONRequestBody PatchLinksCodeJSONBody
+
+// PostLinksCodeRenewJSONRequestBody defines body for PostLinksCodeRenew for application/json ContentType.
+type PostLinksCodeRenewJSONRequestBody PostLinksCodeRenewJSONBody
+
+// PostLinksCodeRollbackJSONRequestBody defines body for PostLinksCodeRollback for application/json ContentType.
+type PostLinksCodeRollbackJSONRequestBody PostLinksCodeRollbackJSONBody
+
 // PostShortenJSONRequestBody defines body for PostShorten for application/json ContentType.
 type PostShortenJSONRequestBody PostShortenJSONBody
+
+// PostShortenBatchJSONRequestBody defines body for PostShortenBatch for application/json ContentType.
+type PostShortenBatchJSONRequestBody PostShortenBatchJSONBody
+
+// PostShortCodeFormdataRequestBody defines body for PostShortCode for application/x-www-form-urlencoded ContentType.
+type PostShortCodeFormdataRequestBody PostShortCodeFormdataBody
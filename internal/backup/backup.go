@@ -0,0 +1,242 @@
+// Package backup exports links as JSON Lines and imports them back.
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/enleur/shrink/internal/shortener"
+)
+
+// Record is one exported link. ExpiresAt and TTLSeconds are omitted for
+// links that never expire, and CreatedAt and Creator for links stored before
+// they were recorded. PasswordHash carries a protected link's password hash,
+// so it stays protected once imported. RedirectType is omitted for links
+// that redirect with the server's default status. MaxClicks and Clicks carry
+// a click limit and the redirects served against it, and NotBefore and
+// NotAfter the activation window; each is omitted when unset. Disabled
+// marks the tombstone of a deleted link, which is imported as one so its
+// code is never handed out again. SlideSeconds and MaxExpiresAt are set for
+// a sliding link. Version and History carry the link's destination changes,
+// so it can still be rolled back once imported.
+type Record struct {
+	Code         string          `json:"code"`
+	URL          string          `json:"url"`
+	ExpiresAt    *time.Time      `json:"expiresAt,omitempty"`
+	TTLSeconds   int64           `json:"ttlSeconds,omitempty"`
+	CreatedAt    *time.Time      `json:"createdAt,omitempty"`
+	Creator      string          `json:"creator,omitempty"`
+	PasswordHash string          `json:"passwordHash,omitempty"`
+	Title        string          `json:"title,omitempty"`
+	Description  string          `json:"description,omitempty"`
+	Tags         []string        `json:"tags,omitempty"`
+	RedirectType int             `json:"redirectType,omitempty"`
+	MaxClicks    int64           `json:"maxClicks,omitempty"`
+	Clicks       int64           `json:"clicks,omitempty"`
+	NotBefore    *time.Time      `json:"notBefore,omitempty"`
+	NotAfter     *time.Time      `json:"notAfter,omitempty"`
+	Disabled     bool            `json:"disabled,omitempty"`
+	SlideSeconds int64           `json:"slideSeconds,omitempty"`
+	MaxExpiresAt *time.Time      `json:"maxExpiresAt,omitempty"`
+	Version      int64           `json:"version,omitempty"`
+	History      []HistoryRecord `json:"history,omitempty"`
+}
+
+// HistoryRecord is one destination change of an exported link.
+type HistoryRecord struct {
+	Version   int64     `json:"version"`
+	URL       string    `json:"url"`
+	Actor     string    `json:"actor,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// ConflictPolicy decides what Import does with a code that already exists.
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictFail      ConflictPolicy = "fail"
+)
+
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy %q", s)
+	}
+}
+
+// ImportStats summarizes an Import run.
+type ImportStats struct {
+	Imported    int
+	Overwritten int
+	Skipped     int
+	Expired     int
+}
+
+// Export writes every link in it to w, one JSON record per line, and returns
+// the number of records written.
+func Export(ctx context.Context, it shortener.Iterator, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	n := 0
+	err := it.Iterate(ctx, func(key string, link shortener.Link) error {
+		record := Record{
+			Code:         key,
+			URL:          link.URL,
+			ExpiresAt:    optionalTime(link.ExpiresAt),
+			CreatedAt:    optionalTime(link.CreatedAt),
+			Creator:      link.Creator,
+			PasswordHash: link.PasswordHash,
+			Title:        link.Title,
+			Description:  link.Description,
+			Tags:         link.Tags,
+			RedirectType: link.RedirectStatus,
+			MaxClicks:    link.MaxClicks,
+			Clicks:       link.Clicks,
+			NotBefore:    optionalTime(link.NotBefore),
+			NotAfter:     optionalTime(link.NotAfter),
+			Disabled:     link.Disabled,
+			SlideSeconds: int64(link.Slide / time.Second),
+			MaxExpiresAt: optionalTime(link.MaxExpiresAt),
+			Version:      link.Version,
+		}
+		for _, entry := range link.History {
+			record.History = append(record.History, HistoryRecord(entry))
+		}
+		if record.ExpiresAt != nil {
+			record.TTLSeconds = int64(time.Until(*record.ExpiresAt).Seconds())
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("failed to export links: %w", err)
+	}
+
+	return n, bw.Flush()
+}
+
+// Import reads records written by Export and stores them under their
+// original codes. A record keeps its absolute expiration when it has one and
+// falls back to ttlSeconds otherwise; records that have already expired are
+// counted and skipped.
+func Import(ctx context.Context, store shortener.Store, r io.Reader, policy ConflictPolicy) (ImportStats, error) {
+	var stats ImportStats
+
+	dec := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var record Record
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return stats, nil
+		}
+		if err != nil {
+			return stats, fmt.Errorf("record %d: %w", line, err)
+		}
+		if record.Code == "" || record.URL == "" {
+			return stats, fmt.Errorf("record %d: code and url are required", line)
+		}
+
+		link := shortener.Link{
+			URL:            record.URL,
+			Creator:        record.Creator,
+			PasswordHash:   record.PasswordHash,
+			Title:          record.Title,
+			Description:    record.Description,
+			Tags:           record.Tags,
+			RedirectStatus: record.RedirectType,
+			MaxClicks:      record.MaxClicks,
+			Clicks:         record.Clicks,
+			Disabled:       record.Disabled,
+			Slide:          time.Duration(record.SlideSeconds) * time.Second,
+			Version:        record.Version,
+		}
+		for _, entry := range record.History {
+			link.History = append(link.History, shortener.HistoryEntry(entry))
+		}
+		switch {
+		case record.ExpiresAt != nil:
+			link.ExpiresAt = *record.ExpiresAt
+		case record.TTLSeconds > 0:
+			link.ExpiresAt = time.Now().Add(time.Duration(record.TTLSeconds) * time.Second)
+		}
+		if !link.ExpiresAt.IsZero() && !time.Now().Before(link.ExpiresAt) {
+			stats.Expired++
+			continue
+		}
+		if record.CreatedAt != nil {
+			link.CreatedAt = *record.CreatedAt
+		}
+		if record.NotBefore != nil {
+			link.NotBefore = *record.NotBefore
+		}
+		if record.NotAfter != nil {
+			link.NotAfter = *record.NotAfter
+		}
+		if record.MaxExpiresAt != nil {
+			link.MaxExpiresAt = *record.MaxExpiresAt
+		}
+
+		err = create(ctx, store, record.Code, link)
+		switch {
+		case err == nil:
+			stats.Imported++
+		case !errors.Is(err, shortener.ErrAlreadyExists):
+			return stats, fmt.Errorf("record %d (%s): %w", line, record.Code, err)
+		case policy == ConflictSkip:
+			stats.Skipped++
+		case policy == ConflictOverwrite:
+			if err := store.Set(ctx, record.Code, link); err != nil {
+				return stats, fmt.Errorf("record %d (%s): %w", line, record.Code, err)
+			}
+			stats.Overwritten++
+		default:
+			return stats, fmt.Errorf("record %d: %w: %s", line, err, record.Code)
+		}
+	}
+}
+
+// optionalTime returns t in UTC, or nil for the zero time.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+// create stores link under code unless the code is taken. Store.Create
+// starts a link afresh, so a link carrying state it would reset is written
+// whole with Set instead, once Get has found the code free; a tombstone is
+// then never briefly live.
+func create(ctx context.Context, store shortener.Store, code string, link shortener.Link) error {
+	if !keepsState(link) {
+		return store.Create(ctx, code, link)
+	}
+	_, err := store.Get(ctx, code)
+	switch {
+	case err == nil:
+		return shortener.ErrAlreadyExists
+	case !errors.Is(err, shortener.ErrNotFound):
+		return err
+	}
+	return store.Set(ctx, code, link)
+}
+
+// keepsState reports whether link carries state that Store.Create resets.
+func keepsState(link shortener.Link) bool {
+	return link.Clicks > 0 || link.Disabled || link.Version > 0 || len(link.History) > 0
+}
@@ -0,0 +1,229 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enleur/shrink/internal/shortener"
+	"github.com/enleur/shrink/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	source := storage.NewMemoryStore(0)
+	defer func() { _ = source.Close() }()
+
+	createdAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, source.Create(ctx, "expiring", shortener.Link{
+		URL:          "https://example.com/a",
+		ExpiresAt:    time.Now().Add(time.Hour),
+		CreatedAt:    createdAt,
+		Creator:      "alice",
+		PasswordHash: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$a2V5",
+	}))
+	require.NoError(t, source.Create(ctx, "forever", shortener.Link{URL: "https://example.com/b"}))
+
+	var buf bytes.Buffer
+	n, err := Export(ctx, source, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 2, strings.Count(buf.String(), "\n"))
+
+	t.Run("Restores Codes, Expiration And Metadata", func(t *testing.T) {
+		target := storage.NewMemoryStore(0)
+		defer func() { _ = target.Close() }()
+
+		stats, err := Import(ctx, target, bytes.NewReader(buf.Bytes()), ConflictFail)
+		require.NoError(t, err)
+		assert.Equal(t, ImportStats{Imported: 2}, stats)
+
+		link, err := target.Get(ctx, "expiring")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/a", link.URL)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), link.ExpiresAt, 5*time.Second)
+		assert.True(t, createdAt.Equal(link.CreatedAt))
+		assert.Equal(t, "alice", link.Creator)
+		assert.Equal(t, "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$a2V5", link.PasswordHash)
+
+		link, err = target.Get(ctx, "forever")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/b", link.URL)
+		assert.True(t, link.ExpiresAt.IsZero())
+		assert.True(t, link.CreatedAt.IsZero())
+	})
+
+	t.Run("Conflict Policies", func(t *testing.T) {
+		target := storage.NewMemoryStore(0)
+		defer func() { _ = target.Close() }()
+		require.NoError(t, target.Set(ctx, "expiring", shortener.Link{URL: "https://example.com/existing", ExpiresAt: time.Now().Add(time.Hour)}))
+		require.NoError(t, target.Set(ctx, "forever", shortener.Link{URL: "https://example.com/existing"}))
+
+		_, err := Import(ctx, target, bytes.NewReader(buf.Bytes()), ConflictFail)
+		assert.ErrorIs(t, err, shortener.ErrAlreadyExists)
+
+		stats, err := Import(ctx, target, bytes.NewReader(buf.Bytes()), ConflictSkip)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, stats.Skipped)
+		link, _ := target.Get(ctx, "forever")
+		assert.Equal(t, "https://example.com/existing", link.URL)
+
+		stats, err = Import(ctx, target, bytes.NewReader(buf.Bytes()), ConflictOverwrite)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, stats.Overwritten)
+		link, _ = target.Get(ctx, "forever")
+		assert.Equal(t, "https://example.com/b", link.URL)
+	})
+
+	t.Run("Skips Expired Records", func(t *testing.T) {
+		target := storage.NewMemoryStore(0)
+		defer func() { _ = target.Close() }()
+
+		input := `{"code":"old","url":"https://example.com","expiresAt":"2000-01-01T00:00:00Z"}
+{"code":"relative","url":"https://example.com","ttlSeconds":60}
+`
+		stats, err := Import(ctx, target, strings.NewReader(input), ConflictFail)
+		assert.NoError(t, err)
+		assert.Equal(t, ImportStats{Imported: 1, Expired: 1}, stats)
+
+		_, err = target.Get(ctx, "old")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("Rejects Invalid Records", func(t *testing.T) {
+		target := storage.NewMemoryStore(0)
+		defer func() { _ = target.Close() }()
+
+		_, err := Import(ctx, target, strings.NewReader(`{"code":"missingURL"}`), ConflictFail)
+		assert.Error(t, err)
+	})
+}
+
+// TestExportImportLinkState checks that a restore keeps the state that
+// decides whether and how a link still redirects.
+func TestExportImportLinkState(t *testing.T) {
+	ctx := context.Background()
+
+	source := storage.NewMemoryStore(0)
+	defer func() { _ = source.Close() }()
+
+	require.NoError(t, source.Create(ctx, "once", shortener.Link{URL: "https://example.com/once", MaxClicks: 2}))
+	_, err := source.Resolve(ctx, "once", time.Now())
+	require.NoError(t, err)
+	opensAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	closesAt := opensAt.Add(24 * time.Hour)
+	require.NoError(t, source.Create(ctx, "campaign", shortener.Link{
+		URL:       "https://example.com/campaign",
+		NotBefore: opensAt,
+		NotAfter:  closesAt,
+	}))
+	ceiling := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+	require.NoError(t, source.Create(ctx, "sliding", shortener.Link{
+		URL:          "https://example.com/sliding",
+		ExpiresAt:    time.Now().Add(time.Hour),
+		Slide:        time.Hour,
+		MaxExpiresAt: ceiling,
+	}))
+	require.NoError(t, source.Create(ctx, "moved", shortener.Link{URL: "https://example.com/old"}))
+	_, err = source.Update(ctx, "moved", time.Now(), func(current shortener.Link) (shortener.Link, error) {
+		current.History = append(current.History, shortener.HistoryEntry{
+			Version: current.Version, URL: current.URL, Actor: "alice", ChangedAt: time.Now().UTC().Truncate(time.Second), Reason: "rebrand",
+		})
+		current.URL = "https://example.com/new"
+		return current, nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, source.Create(ctx, "deleted", shortener.Link{URL: "https://example.com/deleted"}))
+	require.NoError(t, source.Delete(ctx, "deleted"))
+
+	var buf bytes.Buffer
+	_, err = Export(ctx, source, &buf)
+	require.NoError(t, err)
+
+	target := storage.NewMemoryStore(0)
+	defer func() { _ = target.Close() }()
+	_, err = Import(ctx, target, bytes.NewReader(buf.Bytes()), ConflictFail)
+	require.NoError(t, err)
+
+	t.Run("Click Limit", func(t *testing.T) {
+		link, err := target.Get(ctx, "once")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), link.MaxClicks)
+		assert.Equal(t, int64(1), link.Clicks)
+
+		_, err = target.Resolve(ctx, "once", time.Now())
+		assert.NoError(t, err)
+		_, err = target.Resolve(ctx, "once", time.Now())
+		assert.ErrorIs(t, err, shortener.ErrExhausted)
+	})
+
+	t.Run("Activation Window", func(t *testing.T) {
+		link, err := target.Get(ctx, "campaign")
+		require.NoError(t, err)
+		assert.True(t, opensAt.Equal(link.NotBefore))
+		assert.True(t, closesAt.Equal(link.NotAfter))
+
+		_, err = target.Resolve(ctx, "campaign", time.Now())
+		assert.ErrorIs(t, err, shortener.ErrNotYetActive)
+		_, err = target.Resolve(ctx, "campaign", closesAt)
+		assert.ErrorIs(t, err, shortener.ErrExpired)
+	})
+
+	t.Run("Sliding Expiration", func(t *testing.T) {
+		link, err := target.Get(ctx, "sliding")
+		require.NoError(t, err)
+		assert.Equal(t, time.Hour, link.Slide)
+		assert.True(t, ceiling.Equal(link.MaxExpiresAt))
+
+		link, err = target.Resolve(ctx, "sliding", time.Now().Add(30*time.Minute))
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(90*time.Minute), link.ExpiresAt, 5*time.Second)
+	})
+
+	t.Run("History", func(t *testing.T) {
+		want, err := source.Get(ctx, "moved")
+		require.NoError(t, err)
+		link, err := target.Get(ctx, "moved")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/new", link.URL)
+		assert.Equal(t, want.ETag(), link.ETag())
+		require.Len(t, link.History, 1)
+		assert.Equal(t, "https://example.com/old", link.History[0].URL)
+		assert.Equal(t, "alice", link.History[0].Actor)
+		assert.Equal(t, "rebrand", link.History[0].Reason)
+		assert.True(t, want.History[0].ChangedAt.Equal(link.History[0].ChangedAt))
+	})
+
+	t.Run("Tombstone", func(t *testing.T) {
+		_, err := target.Resolve(ctx, "deleted", time.Now())
+		assert.ErrorIs(t, err, shortener.ErrDisabled)
+		err = target.Create(ctx, "deleted", shortener.Link{URL: "https://example.com/reused"})
+		assert.ErrorIs(t, err, shortener.ErrAlreadyExists)
+	})
+
+	t.Run("Existing Codes Conflict", func(t *testing.T) {
+		_, err := Import(ctx, target, bytes.NewReader(buf.Bytes()), ConflictFail)
+		assert.ErrorIs(t, err, shortener.ErrAlreadyExists)
+
+		stats, err := Import(ctx, target, bytes.NewReader(buf.Bytes()), ConflictSkip)
+		require.NoError(t, err)
+		assert.Equal(t, ImportStats{Skipped: 5}, stats)
+		link, err := target.Get(ctx, "moved")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/new", link.URL)
+	})
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	policy, err := ParseConflictPolicy("overwrite")
+	assert.NoError(t, err)
+	assert.Equal(t, ConflictOverwrite, policy)
+
+	_, err = ParseConflictPolicy("merge")
+	assert.Error(t, err)
+}
@@ -2,24 +2,125 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 )
 
 type Config struct {
-	Server ServerConfig
-	Redis  RedisConfig
-	Otel   OtelConfig
+	Server    ServerConfig
+	Shortener ShortenerConfig
+	Store     StoreConfig
+	Cache     CacheConfig
+	Redis     RedisConfig
+	SQL       SQLConfig
+	Bolt      BoltConfig
+	Memory    MemoryConfig
+	Otel      OtelConfig
 }
 
 type ServerConfig struct {
 	Port int    `env:"SERVER_PORT" envDefault:"8080"`
 	Mode string `env:"GIN_MODE" envDefault:"debug"`
+	// NotActiveStatus answers redirects to links whose activation window
+	// hasn't opened yet, unless NotActiveRedirect names a page to send
+	// visitors to instead.
+	NotActiveStatus   int    `env:"SERVER_NOT_ACTIVE_STATUS" envDefault:"404"`
+	NotActiveRedirect string `env:"SERVER_NOT_ACTIVE_REDIRECT"`
+	// RedirectStatus is the status links redirect with unless they were
+	// given their own: 301, 302, 307 or 308.
+	RedirectStatus int `env:"SERVER_REDIRECT_STATUS" envDefault:"302"`
+	// AdminUsers lists the users, as named by the X-Forwarded-User header,
+	// allowed to list every stored link. Nobody is when it is empty.
+	AdminUsers []string `env:"SERVER_ADMIN_USERS"`
+	// TrustedProxies lists the addresses or CIDR ranges of the
+	// authenticating proxies in front of the server. X-Forwarded-User only
+	// names an admin on requests that come from one of them, since any
+	// other client can set the header itself.
+	TrustedProxies []string `env:"SERVER_TRUSTED_PROXIES"`
+}
+
+type ShortenerConfig struct {
+	MaxAttempts int `env:"SHORTENER_MAX_ATTEMPTS" envDefault:"5"`
+	// DefaultTTL applies to links created without an expiration; zero makes
+	// them permanent. MaxTTL caps requested lifetimes; zero means no cap.
+	DefaultTTL time.Duration `env:"SHORTENER_DEFAULT_TTL" envDefault:"24h"`
+	MaxTTL     time.Duration `env:"SHORTENER_MAX_TTL" envDefault:"0"`
+	// CodeStrategy is random, counter or feistel.
+	CodeStrategy string `env:"SHORTENER_CODE_STRATEGY" envDefault:"random"`
+	CodeLength   int    `env:"SHORTENER_CODE_LENGTH" envDefault:"6"`
+	CodeAlphabet string `env:"SHORTENER_CODE_ALPHABET" envDefault:"0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"`
+	// FeistelKey keys the feistel strategy's permutation. Changing it
+	// changes every future code, and may reissue codes already handed out.
+	FeistelKey string `env:"SHORTENER_FEISTEL_KEY"`
+	// SlidingTTL is how far each redirect pushes out the expiration of a
+	// sliding link, and SlidingMaxTTL how long after creation it expires
+	// regardless. A zero SlidingTTL turns sliding links off.
+	SlidingTTL    time.Duration `env:"SHORTENER_SLIDING_TTL" envDefault:"24h"`
+	SlidingMaxTTL time.Duration `env:"SHORTENER_SLIDING_MAX_TTL" envDefault:"720h"`
+	// HistorySize is how many past destinations are kept per link.
+	HistorySize int `env:"SHORTENER_HISTORY_SIZE" envDefault:"20"`
+	// PasswordAttempts is how many incorrect passwords a protected link
+	// accepts before it is locked for the rest of PasswordLockout. Zero
+	// turns the lockout off.
+	PasswordAttempts int           `env:"SHORTENER_PASSWORD_ATTEMPTS" envDefault:"5"`
+	PasswordLockout  time.Duration `env:"SHORTENER_PASSWORD_LOCKOUT" envDefault:"15m"`
+	// BatchLimit is how many URLs POST /shorten/batch takes at once.
+	BatchLimit int `env:"SHORTENER_BATCH_LIMIT" envDefault:"500"`
+}
+
+type StoreConfig struct {
+	Backend string `env:"STORE_BACKEND" envDefault:"redis"`
+}
+
+type CacheConfig struct {
+	Enabled     bool          `env:"CACHE_ENABLED" envDefault:"false"`
+	Size        int           `env:"CACHE_SIZE" envDefault:"10000"`
+	TTL         time.Duration `env:"CACHE_TTL" envDefault:"1m"`
+	NegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL" envDefault:"5s"`
 }
 
 type RedisConfig struct {
-	Address string `env:"REDIS_ADDRESS" envDefault:"localhost:6379"`
-	DB      int    `env:"REDIS_DB" envDefault:"0"`
+	// Mode is one of standalone, sentinel or cluster.
+	Mode string `env:"REDIS_MODE" envDefault:"standalone"`
+	// Addresses lists the server, sentinel or cluster seed addresses,
+	// comma-separated.
+	Addresses []string `env:"REDIS_ADDRESS" envDefault:"localhost:6379"`
+	DB        int      `env:"REDIS_DB" envDefault:"0"`
+	Username  string   `env:"REDIS_USERNAME"`
+	Password  string   `env:"REDIS_PASSWORD"`
+	// KeyPrefix namespaces link keys, so a shared Redis can be scanned for
+	// links and ACLs can restrict the service to its own keys.
+	KeyPrefix string `env:"REDIS_KEY_PREFIX"`
+
+	SentinelMaster   string `env:"REDIS_SENTINEL_MASTER"`
+	SentinelUsername string `env:"REDIS_SENTINEL_USERNAME"`
+	SentinelPassword string `env:"REDIS_SENTINEL_PASSWORD"`
+
+	TLS RedisTLSConfig
+}
+
+type RedisTLSConfig struct {
+	Enabled            bool   `env:"REDIS_TLS_ENABLED" envDefault:"false"`
+	CAFile             string `env:"REDIS_TLS_CA_FILE"`
+	CertFile           string `env:"REDIS_TLS_CERT_FILE"`
+	KeyFile            string `env:"REDIS_TLS_KEY_FILE"`
+	ServerName         string `env:"REDIS_TLS_SERVER_NAME"`
+	InsecureSkipVerify bool   `env:"REDIS_TLS_INSECURE_SKIP_VERIFY" envDefault:"false"`
+}
+
+type SQLConfig struct {
+	Driver string `env:"SQL_DRIVER" envDefault:"sqlite"`
+	DSN    string `env:"SQL_DSN" envDefault:"file:shrink.db"`
+}
+
+type BoltConfig struct {
+	DataDir       string        `env:"BOLT_DATA_DIR" envDefault:"data"`
+	SweepInterval time.Duration `env:"BOLT_SWEEP_INTERVAL" envDefault:"1m"`
+}
+
+type MemoryConfig struct {
+	CleanupInterval time.Duration `env:"MEMORY_CLEANUP_INTERVAL" envDefault:"1m"`
 }
 
 type OtelConfig struct {
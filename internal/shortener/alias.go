@@ -0,0 +1,41 @@
+package shortener
+
+import "strings"
+
+const (
+	minAliasLength = 3
+	maxAliasLength = 64
+)
+
+// reservedAliases are paths served by the API itself, or kept for it, which
+// a short code must not shadow. They are compared case-insensitively.
+var reservedAliases = map[string]bool{
+	"healthz": true,
+	"links":   true,
+	"metrics": true,
+	"shorten": true,
+}
+
+// validateAlias accepts letters, digits, '-' and '_', between
+// minAliasLength and maxAliasLength characters, that aren't reserved.
+func validateAlias(alias string) error {
+	if len(alias) < minAliasLength || len(alias) > maxAliasLength {
+		return InvalidAliasError{Alias: alias, Reason: "must be between 3 and 64 characters"}
+	}
+	for _, c := range alias {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return InvalidAliasError{Alias: alias, Reason: "may only contain letters, digits, '-' and '_'"}
+		}
+	}
+	if reserved(alias) {
+		return InvalidAliasError{Alias: alias, Reason: "is reserved"}
+	}
+	return nil
+}
+
+// reserved reports whether code is one of the reservedAliases. Generated
+// codes are checked too, since the counter and feistel strategies can spell
+// any word their alphabet allows.
+func reserved(code string) bool {
+	return reservedAliases[strings.ToLower(code)]
+}
@@ -0,0 +1,144 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// BatchItem is one URL for ShortenBatch to shorten, with the same options
+// ShortenURL takes.
+type BatchItem struct {
+	URL  string
+	Opts ShortenOptions
+}
+
+// BatchResult is the outcome of one BatchItem: the link created or reused
+// for it, or the error that kept it from being shortened.
+type BatchResult struct {
+	ShortenResult
+	Err error
+}
+
+// ShortenBatch shortens every item as ShortenURL would, but writes the new
+// links to the store together. Results are in the order of items, and an
+// item that fails doesn't affect the others. The error is only set when the
+// batch as a whole is refused, for holding more items than the service's
+// batch limit.
+func (s *Service) ShortenBatch(ctx context.Context, items []BatchItem) ([]BatchResult, error) {
+	ctx, span := s.tracer.Start(ctx, "ShortenBatch")
+	defer span.End()
+
+	if len(items) > s.batchLimit {
+		return nil, BatchTooLargeError{Limit: s.batchLimit}
+	}
+
+	now := s.now()
+	results := make([]BatchResult, len(items))
+	links := make([]Link, len(items))
+	var dedup []int
+	for i, item := range items {
+		link, err := s.newLink(item.URL, item.Opts, now)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		links[i] = link
+		if item.Opts.Alias == "" && !item.Opts.ForceNew && link.Shareable() && !link.labeled() {
+			dedup = append(dedup, i)
+		}
+	}
+
+	// Items that may reuse a live link look their URLs up together.
+	if len(dedup) > 0 {
+		urls := make([]string, len(dedup))
+		for j, i := range dedup {
+			urls[j] = links[i].URL
+		}
+		matches, err := s.store.FindByURLs(ctx, urls)
+		for j, i := range dedup {
+			match := URLMatch{Err: err}
+			if err == nil {
+				match = matches[j]
+			}
+			existing, ok, err := reuseExisting(match, links[i], items[i].Opts)
+			if err != nil {
+				results[i].Err = err
+			} else if ok {
+				results[i].ShortenResult = existing
+			}
+		}
+	}
+
+	var pending []int
+	for i := range items {
+		if results[i].Err == nil && results[i].Code == "" {
+			pending = append(pending, i)
+		}
+	}
+
+	// Items that lose a generated code to a collision, or are handed a
+	// reserved one, go around again with a new one, up to the service's
+	// attempt limit.
+	for attempt := 1; len(pending) > 0; attempt++ {
+		entries := make([]LinkEntry, 0, len(pending))
+		indexes := make([]int, 0, len(pending))
+		var retry []int
+		for _, i := range pending {
+			code := items[i].Opts.Alias
+			if code == "" {
+				var err error
+				if code, err = s.codes.Generate(ctx); err != nil {
+					results[i].Err = fmt.Errorf("failed to generate short code: %w", err)
+					continue
+				}
+				if reserved(code) {
+					span.AddEvent("reserved short code skipped")
+					if attempt >= s.maxAttempts {
+						results[i].Err = fmt.Errorf("no free short code after %d attempts: %w", attempt, ErrCodeSpaceExhausted)
+					} else {
+						retry = append(retry, i)
+					}
+					continue
+				}
+			}
+			entries = append(entries, LinkEntry{Key: code, Link: links[i]})
+			indexes = append(indexes, i)
+		}
+		if len(entries) == 0 {
+			pending = retry
+			continue
+		}
+
+		errs, err := s.store.CreateBatch(ctx, entries)
+		if err != nil {
+			err = fmt.Errorf("failed to store URLs: %w", StorageError{Op: "create_batch", Err: err})
+			for _, i := range append(indexes, retry...) {
+				results[i].Err = err
+			}
+			break
+		}
+
+		pending = retry
+		for j, i := range indexes {
+			err := errs[j]
+			switch {
+			case err == nil:
+				results[i].ShortenResult = ShortenResult{Code: entries[j].Key, ExpiresAt: links[i].ExpiresAt}
+			case !errors.Is(err, ErrAlreadyExists):
+				results[i].Err = fmt.Errorf("failed to store URL: %w", StorageError{Op: "create", Err: err})
+			case items[i].Opts.Alias != "":
+				results[i].Err = AliasTakenError{Alias: items[i].Opts.Alias}
+			case attempt >= s.maxAttempts:
+				codeCollisionsTotal.Inc()
+				results[i].Err = fmt.Errorf("no free short code after %d attempts: %w", attempt, ErrCodeSpaceExhausted)
+			default:
+				codeCollisionsTotal.Inc()
+				span.AddEvent("short code collision")
+				pending = append(pending, i)
+			}
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,233 @@
+package shortener
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+const (
+	CodeStrategyRandom  = "random"
+	CodeStrategyCounter = "counter"
+	CodeStrategyFeistel = "feistel"
+)
+
+// Base62Alphabet is the default short code alphabet.
+const Base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// CodeGenerator produces candidate short codes for ShortenURL.
+type CodeGenerator interface {
+	Generate(ctx context.Context) (string, error)
+}
+
+// Counter hands out unique, increasing numbers starting at 1. Stores that
+// can keep a shared counter implement it for the counter-based generators.
+type Counter interface {
+	Next(ctx context.Context) (uint64, error)
+}
+
+// validateAlphabet accepts alphabets of URL-safe unreserved characters
+// (RFC 3986), with no repeats.
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return errors.New("alphabet needs at least two characters")
+	}
+	for i, c := range alphabet {
+		if !isUnreserved(c) {
+			return fmt.Errorf("alphabet character %q is not URL-safe", c)
+		}
+		if strings.IndexRune(alphabet, c) != i {
+			return fmt.Errorf("alphabet character %q is repeated", c)
+		}
+	}
+	return nil
+}
+
+func isUnreserved(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '_' || c == '.' || c == '~'
+}
+
+// RandomGenerator draws each character uniformly from an alphabet using
+// crypto/rand.
+type RandomGenerator struct {
+	alphabet string
+	length   int
+}
+
+func NewRandomGenerator(alphabet string, length int) (*RandomGenerator, error) {
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if length < 1 {
+		return nil, errors.New("code length must be positive")
+	}
+	return &RandomGenerator{alphabet: alphabet, length: length}, nil
+}
+
+func (g *RandomGenerator) Generate(context.Context) (string, error) {
+	// Reject bytes past the largest multiple of the alphabet size so every
+	// character is equally likely.
+	n := len(g.alphabet)
+	limit := 256 - 256%n
+
+	code := make([]byte, 0, g.length)
+	buf := make([]byte, g.length*2)
+	for len(code) < g.length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			code = append(code, g.alphabet[int(b)%n])
+			if len(code) == g.length {
+				break
+			}
+		}
+	}
+
+	return string(code), nil
+}
+
+// CounterGenerator encodes successive counter values in the alphabet, so
+// codes never collide and grow in length as the counter does.
+type CounterGenerator struct {
+	counter  Counter
+	alphabet string
+}
+
+func NewCounterGenerator(counter Counter, alphabet string) (*CounterGenerator, error) {
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	return &CounterGenerator{counter: counter, alphabet: alphabet}, nil
+}
+
+func (g *CounterGenerator) Generate(ctx context.Context) (string, error) {
+	n, err := g.counter.Next(ctx)
+	if err != nil {
+		return "", StorageError{Op: "counter", Err: err}
+	}
+	return encode(n, g.alphabet, 0), nil
+}
+
+// feistelRounds is the number of Feistel rounds; four rounds of a keyed
+// pseudo-random function give a pseudo-random permutation.
+const feistelRounds = 4
+
+// FeistelGenerator runs counter values through a keyed Feistel permutation
+// of [0, len(alphabet)^length). Codes have a fixed length, never collide
+// and, without the key, don't reveal the counter or the next code.
+type FeistelGenerator struct {
+	counter  Counter
+	alphabet string
+	length   int
+	key      []byte
+
+	space    uint64
+	halfBits uint
+}
+
+func NewFeistelGenerator(counter Counter, alphabet string, length int, key []byte) (*FeistelGenerator, error) {
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if length < 1 {
+		return nil, errors.New("code length must be positive")
+	}
+	if len(key) < 16 {
+		return nil, errors.New("feistel key must be at least 16 bytes")
+	}
+
+	space := uint64(1)
+	for i := 0; i < length; i++ {
+		if space > math.MaxUint64/2/uint64(len(alphabet)) {
+			return nil, fmt.Errorf("%d characters of a %d character alphabet is too large a code space", length, len(alphabet))
+		}
+		space *= uint64(len(alphabet))
+	}
+
+	// Split the smallest power of two covering the space into two equal
+	// halves; values past the space are cycle-walked back into it.
+	width := uint(bits.Len64(space - 1))
+	width += width % 2
+
+	return &FeistelGenerator{
+		counter:  counter,
+		alphabet: alphabet,
+		length:   length,
+		key:      key,
+		space:    space,
+		halfBits: width / 2,
+	}, nil
+}
+
+func (g *FeistelGenerator) Generate(ctx context.Context) (string, error) {
+	n, err := g.counter.Next(ctx)
+	if err != nil {
+		return "", StorageError{Op: "counter", Err: err}
+	}
+	// Counters start at 1; the permutation domain starts at 0.
+	if n == 0 || n > g.space {
+		return "", ErrCodeSpaceExhausted
+	}
+	return encode(g.permute(n-1), g.alphabet, g.length), nil
+}
+
+// permute maps x in [0, space) to a unique value in [0, space).
+func (g *FeistelGenerator) permute(x uint64) uint64 {
+	for {
+		x = g.encrypt(x)
+		if x < g.space {
+			return x
+		}
+	}
+}
+
+func (g *FeistelGenerator) encrypt(x uint64) uint64 {
+	mask := uint64(1)<<g.halfBits - 1
+	left, right := x>>g.halfBits, x&mask
+	for round := 0; round < feistelRounds; round++ {
+		left, right = right, left^(g.round(round, right)&mask)
+	}
+	return left<<g.halfBits | right
+}
+
+func (g *FeistelGenerator) round(round int, half uint64) uint64 {
+	var msg [9]byte
+	msg[0] = byte(round)
+	binary.BigEndian.PutUint64(msg[1:], half)
+
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write(msg[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// encode writes n in the base of the alphabet, left-padded with the first
+// alphabet character to at least width characters.
+func encode(n uint64, alphabet string, width int) string {
+	base := uint64(len(alphabet))
+
+	var buf [64]byte
+	i := len(buf)
+	for n > 0 || i == len(buf) {
+		i--
+		buf[i] = alphabet[n%base]
+		n /= base
+	}
+	for len(buf)-i < width {
+		i--
+		buf[i] = alphabet[0]
+	}
+
+	return string(buf[i:])
+}
@@ -0,0 +1,134 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sequenceCounter struct {
+	n   uint64
+	err error
+}
+
+func (c *sequenceCounter) Next(context.Context) (uint64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.n++
+	return c.n, nil
+}
+
+func TestRandomGenerator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Length And Alphabet", func(t *testing.T) {
+		g, err := NewRandomGenerator("abc", 10)
+		require.NoError(t, err)
+
+		for i := 0; i < 100; i++ {
+			code, err := g.Generate(ctx)
+			require.NoError(t, err)
+			assert.Len(t, code, 10)
+			assert.Empty(t, strings.Trim(code, "abc"))
+		}
+	})
+
+	t.Run("Invalid Config", func(t *testing.T) {
+		_, err := NewRandomGenerator("a", 6)
+		assert.Error(t, err)
+		_, err = NewRandomGenerator("aba", 6)
+		assert.Error(t, err)
+		_, err = NewRandomGenerator("ab/", 6)
+		assert.Error(t, err)
+		_, err = NewRandomGenerator(Base62Alphabet, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestCounterGenerator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Encodes Counter", func(t *testing.T) {
+		counter := &sequenceCounter{n: 59}
+		g, err := NewCounterGenerator(counter, Base62Alphabet)
+		require.NoError(t, err)
+
+		var codes []string
+		for i := 0; i < 3; i++ {
+			code, err := g.Generate(ctx)
+			require.NoError(t, err)
+			codes = append(codes, code)
+		}
+		assert.Equal(t, []string{"y", "z", "10"}, codes)
+	})
+
+	t.Run("Counter Failure", func(t *testing.T) {
+		g, err := NewCounterGenerator(&sequenceCounter{err: errors.New("connection refused")}, Base62Alphabet)
+		require.NoError(t, err)
+
+		_, err = g.Generate(ctx)
+		assert.Equal(t, KindUnavailable, ErrorKind(err))
+	})
+}
+
+func TestFeistelGenerator(t *testing.T) {
+	ctx := context.Background()
+	key := []byte("0123456789abcdef")
+
+	t.Run("Unique Fixed Length Codes", func(t *testing.T) {
+		g, err := NewFeistelGenerator(&sequenceCounter{}, Base62Alphabet, 6, key)
+		require.NoError(t, err)
+
+		seen := make(map[string]bool)
+		for i := 0; i < 10000; i++ {
+			code, err := g.Generate(ctx)
+			require.NoError(t, err)
+			require.Len(t, code, 6)
+			require.False(t, seen[code], "duplicate code %q", code)
+			seen[code] = true
+		}
+	})
+
+	t.Run("Permutes Whole Space", func(t *testing.T) {
+		// 3^3 = 27 codes, inside a 32 value Feistel domain, so cycle walking
+		// is exercised.
+		g, err := NewFeistelGenerator(&sequenceCounter{}, "abc", 3, key)
+		require.NoError(t, err)
+
+		seen := make(map[string]bool)
+		for i := 0; i < 27; i++ {
+			code, err := g.Generate(ctx)
+			require.NoError(t, err)
+			seen[code] = true
+		}
+		assert.Len(t, seen, 27)
+
+		_, err = g.Generate(ctx)
+		assert.ErrorIs(t, err, ErrCodeSpaceExhausted)
+	})
+
+	t.Run("Depends On Key", func(t *testing.T) {
+		a, err := NewFeistelGenerator(&sequenceCounter{}, Base62Alphabet, 6, key)
+		require.NoError(t, err)
+		b, err := NewFeistelGenerator(&sequenceCounter{}, Base62Alphabet, 6, []byte("fedcba9876543210"))
+		require.NoError(t, err)
+
+		codeA, err := a.Generate(ctx)
+		require.NoError(t, err)
+		codeB, err := b.Generate(ctx)
+		require.NoError(t, err)
+		assert.NotEqual(t, codeA, codeB)
+	})
+
+	t.Run("Invalid Config", func(t *testing.T) {
+		_, err := NewFeistelGenerator(&sequenceCounter{}, Base62Alphabet, 6, []byte("short"))
+		assert.Error(t, err)
+		_, err = NewFeistelGenerator(&sequenceCounter{}, Base62Alphabet, 11, key)
+		assert.Error(t, err)
+	})
+}
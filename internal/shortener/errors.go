@@ -1,6 +1,45 @@
 package shortener
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by a Store when no link exists for a key.
+	ErrNotFound = errors.New("short code not found")
+	// ErrExpired is returned by a Store that still knows about a link whose
+	// expiration has passed. Stores that drop expired keys return ErrNotFound.
+	ErrExpired = errors.New("short code expired")
+	// ErrNotYetActive is returned by Store.Resolve for a link whose
+	// activation window hasn't opened yet.
+	ErrNotYetActive = errors.New("short code not active yet")
+	// ErrExhausted is returned by Store.Resolve for a link that has served
+	// all the redirects its click limit allows.
+	ErrExhausted = errors.New("short code click limit reached")
+	// ErrDisabled is returned by a Store for a deleted link. Its tombstone
+	// keeps the code from being handed out again.
+	ErrDisabled = errors.New("short code disabled")
+	// ErrAlreadyExists is returned by Store.Create when the key is taken.
+	ErrAlreadyExists = errors.New("short code already exists")
+	// ErrPreconditionFailed is returned by UpdateLink when the link no
+	// longer matches the ETag the caller based the update on.
+	ErrPreconditionFailed = errors.New("link has changed")
+	// ErrPasswordRequired is returned by Store.Resolve for a
+	// password-protected link, which is only followed through Unlock.
+	ErrPasswordRequired = errors.New("short code is password protected")
+	// ErrIncorrectPassword is returned by UnlockLink for a password that
+	// doesn't match the link's.
+	ErrIncorrectPassword = errors.New("incorrect password")
+	// ErrNoHistory is returned by RollbackLink for a link that has never
+	// been repointed, or whose history has been trimmed away.
+	ErrNoHistory = errors.New("link has no earlier destination to roll back to")
+	// ErrCodeSpaceExhausted is returned by a CodeGenerator that has handed
+	// out every code its alphabet and length allow, and by the Service when
+	// every code it tried for a link was already taken.
+	ErrCodeSpaceExhausted = errors.New("short code space exhausted")
+)
 
 type InvalidURLError struct {
 	Reason string
@@ -9,3 +48,207 @@ type InvalidURLError struct {
 func (e InvalidURLError) Error() string {
 	return fmt.Sprintf("invalid URL: %s", e.Reason)
 }
+
+// InvalidExpirationError is returned when the requested lifetime of a link
+// is malformed or outside what the service allows.
+type InvalidExpirationError struct {
+	Reason string
+}
+
+func (e InvalidExpirationError) Error() string {
+	return fmt.Sprintf("invalid expiration: %s", e.Reason)
+}
+
+type InvalidClickLimitError struct {
+	Reason string
+}
+
+func (e InvalidClickLimitError) Error() string {
+	return fmt.Sprintf("invalid click limit: %s", e.Reason)
+}
+
+type InvalidWindowError struct {
+	Reason string
+}
+
+func (e InvalidWindowError) Error() string {
+	return fmt.Sprintf("invalid activation window: %s", e.Reason)
+}
+
+type InvalidAliasError struct {
+	Alias  string
+	Reason string
+}
+
+func (e InvalidAliasError) Error() string {
+	return fmt.Sprintf("invalid alias %q: %s", e.Alias, e.Reason)
+}
+
+type InvalidPasswordError struct {
+	Reason string
+}
+
+func (e InvalidPasswordError) Error() string {
+	return fmt.Sprintf("invalid password: %s", e.Reason)
+}
+
+// InvalidMetadataError is returned for tags, titles or descriptions a link
+// can't carry.
+type InvalidMetadataError struct {
+	Reason string
+}
+
+func (e InvalidMetadataError) Error() string {
+	return fmt.Sprintf("invalid link metadata: %s", e.Reason)
+}
+
+// InvalidRedirectError is returned for a redirect status links can't use.
+type InvalidRedirectError struct {
+	Status int
+}
+
+func (e InvalidRedirectError) Error() string {
+	return fmt.Sprintf("invalid redirect type %d: must be one of 301, 302, 307 and 308", e.Status)
+}
+
+// InvalidFilterError is returned by ListLinks for a malformed filter,
+// cursor or limit.
+type InvalidFilterError struct {
+	Reason string
+}
+
+func (e InvalidFilterError) Error() string {
+	return fmt.Sprintf("invalid filter: %s", e.Reason)
+}
+
+// BatchTooLargeError is returned by ShortenBatch for more items than the
+// service takes at once.
+type BatchTooLargeError struct {
+	Limit int
+}
+
+func (e BatchTooLargeError) Error() string {
+	return fmt.Sprintf("a batch may hold at most %d items", e.Limit)
+}
+
+// UnknownVersionError is returned by RollbackLink for a version that the
+// link's history doesn't hold, either because it never existed or because
+// it has aged out.
+type UnknownVersionError struct {
+	Version int64
+}
+
+func (e UnknownVersionError) Error() string {
+	return fmt.Sprintf("version %d is not in the link's history", e.Version)
+}
+
+// TooManyAttemptsError is returned by UnlockLink while a code is locked
+// after too many incorrect passwords.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("too many incorrect passwords, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// AliasTakenError is returned when a requested alias already holds a live
+// link. It unwraps to ErrAlreadyExists.
+type AliasTakenError struct {
+	Alias string
+}
+
+func (e AliasTakenError) Error() string {
+	return fmt.Sprintf("alias %q is already taken", e.Alias)
+}
+
+func (e AliasTakenError) Unwrap() error {
+	return ErrAlreadyExists
+}
+
+// StorageError reports a failure of the underlying Store, as opposed to a
+// lookup that completed and found nothing.
+type StorageError struct {
+	Op  string
+	Err error
+}
+
+func (e StorageError) Error() string {
+	return fmt.Sprintf("storage %s failed: %s", e.Op, e.Err)
+}
+
+func (e StorageError) Unwrap() error {
+	return e.Err
+}
+
+// Error kinds reported by ErrorKind.
+const (
+	KindNotFound     = "not_found"
+	KindExpired      = "expired"
+	KindExhausted    = "exhausted"
+	KindNotActive    = "not_active"
+	KindDisabled     = "disabled"
+	KindConflict     = "conflict"
+	KindPrecondition = "precondition_failed"
+	KindPassword     = "password_required"
+	KindUnauthorized = "unauthorized"
+	KindThrottled    = "throttled"
+	KindInvalid      = "invalid"
+	KindUnavailable  = "unavailable"
+	KindInternal     = "internal"
+)
+
+// ErrorKind classifies err into one of the Kind constants so that callers
+// can pick a response, a log level and a metric label without repeating the
+// errors.Is/As chain. It returns an empty string for a nil error.
+func ErrorKind(err error) string {
+	var invalidURLErr InvalidURLError
+	var invalidAliasErr InvalidAliasError
+	var invalidExpirationErr InvalidExpirationError
+	var invalidClickLimitErr InvalidClickLimitError
+	var invalidWindowErr InvalidWindowError
+	var invalidPasswordErr InvalidPasswordError
+	var invalidMetadataErr InvalidMetadataError
+	var invalidRedirectErr InvalidRedirectError
+	var invalidFilterErr InvalidFilterError
+	var batchTooLargeErr BatchTooLargeError
+	var unknownVersionErr UnknownVersionError
+	var tooManyAttemptsErr TooManyAttemptsError
+	var storageErr StorageError
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNotFound):
+		return KindNotFound
+	case errors.Is(err, ErrExpired):
+		return KindExpired
+	case errors.Is(err, ErrExhausted):
+		return KindExhausted
+	case errors.Is(err, ErrNotYetActive):
+		return KindNotActive
+	case errors.Is(err, ErrDisabled):
+		return KindDisabled
+	case errors.Is(err, ErrAlreadyExists):
+		return KindConflict
+	case errors.Is(err, ErrPreconditionFailed):
+		return KindPrecondition
+	case errors.Is(err, ErrPasswordRequired):
+		return KindPassword
+	case errors.Is(err, ErrIncorrectPassword):
+		return KindUnauthorized
+	case errors.As(err, &tooManyAttemptsErr):
+		return KindThrottled
+	case errors.Is(err, ErrNoHistory):
+		return KindInvalid
+	case errors.As(err, &invalidURLErr), errors.As(err, &invalidAliasErr), errors.As(err, &invalidExpirationErr),
+		errors.As(err, &invalidClickLimitErr), errors.As(err, &invalidWindowErr), errors.As(err, &invalidPasswordErr),
+		errors.As(err, &invalidMetadataErr), errors.As(err, &invalidFilterErr), errors.As(err, &batchTooLargeErr),
+		errors.As(err, &unknownVersionErr), errors.As(err, &invalidRedirectErr):
+		return KindInvalid
+	case errors.As(err, &storageErr):
+		return KindUnavailable
+	default:
+		return KindInternal
+	}
+}
@@ -0,0 +1,192 @@
+package shortener
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedStore wraps a Store with per-operation spans, latency
+// histograms, error counters and in-flight gauges. NewService applies it to
+// every store, so backends don't need to instrument themselves.
+type instrumentedStore struct {
+	store  Store
+	tracer trace.Tracer
+}
+
+func newInstrumentedStore(store Store, tracer trace.Tracer) *instrumentedStore {
+	return &instrumentedStore{store: store, tracer: tracer}
+}
+
+func (s *instrumentedStore) Set(ctx context.Context, key string, link Link) error {
+	return s.observe(ctx, "set", key, func(ctx context.Context) error {
+		return s.store.Set(ctx, key, link)
+	})
+}
+
+func (s *instrumentedStore) Create(ctx context.Context, key string, link Link) error {
+	return s.observe(ctx, "create", key, func(ctx context.Context) error {
+		return s.store.Create(ctx, key, link)
+	})
+}
+
+func (s *instrumentedStore) Get(ctx context.Context, key string) (Link, error) {
+	var link Link
+	err := s.observe(ctx, "get", key, func(ctx context.Context) error {
+		var err error
+		link, err = s.store.Get(ctx, key)
+		return err
+	})
+	return link, err
+}
+
+func (s *instrumentedStore) Resolve(ctx context.Context, key string, now time.Time) (Link, error) {
+	var link Link
+	err := s.observe(ctx, "resolve", key, func(ctx context.Context) error {
+		var err error
+		link, err = s.store.Resolve(ctx, key, now)
+		return err
+	})
+	return link, err
+}
+
+// CreateBatch is observed as a single operation, without a key.
+func (s *instrumentedStore) CreateBatch(ctx context.Context, entries []LinkEntry) ([]error, error) {
+	var errs []error
+	err := s.observe(ctx, "create_batch", "", func(ctx context.Context) error {
+		var err error
+		errs, err = s.store.CreateBatch(ctx, entries)
+		return err
+	})
+	return errs, err
+}
+
+// Search is observed without a key.
+func (s *instrumentedStore) Search(ctx context.Context, filter LinkFilter, after string, limit int) ([]LinkEntry, error) {
+	var entries []LinkEntry
+	err := s.observe(ctx, "search", "", func(ctx context.Context) error {
+		var err error
+		entries, err = s.store.Search(ctx, filter, after, limit)
+		return err
+	})
+	return entries, err
+}
+
+// Scan is observed without a key.
+func (s *instrumentedStore) Scan(ctx context.Context, cursor string, limit int) ([]LinkEntry, string, error) {
+	var entries []LinkEntry
+	var next string
+	err := s.observe(ctx, "scan", "", func(ctx context.Context) error {
+		var err error
+		entries, next, err = s.store.Scan(ctx, cursor, limit)
+		return err
+	})
+	return entries, next, err
+}
+
+func (s *instrumentedStore) Unlock(ctx context.Context, key string, now time.Time) (Link, error) {
+	var link Link
+	err := s.observe(ctx, "unlock", key, func(ctx context.Context) error {
+		var err error
+		link, err = s.store.Unlock(ctx, key, now)
+		return err
+	})
+	return link, err
+}
+
+// FindByURL is observed without a key, so destinations stay out of traces.
+func (s *instrumentedStore) FindByURL(ctx context.Context, url string) (string, time.Time, error) {
+	var key string
+	var expiresAt time.Time
+	err := s.observe(ctx, "find_by_url", "", func(ctx context.Context) error {
+		var err error
+		key, expiresAt, err = s.store.FindByURL(ctx, url)
+		return err
+	})
+	return key, expiresAt, err
+}
+
+// FindByURLs is observed as a single operation, without a key.
+func (s *instrumentedStore) FindByURLs(ctx context.Context, urls []string) ([]URLMatch, error) {
+	var matches []URLMatch
+	err := s.observe(ctx, "find_by_urls", "", func(ctx context.Context) error {
+		var err error
+		matches, err = s.store.FindByURLs(ctx, urls)
+		return err
+	})
+	return matches, err
+}
+
+func (s *instrumentedStore) Update(ctx context.Context, key string, now time.Time, fn func(Link) (Link, error)) (Link, error) {
+	var link Link
+	err := s.observe(ctx, "update", key, func(ctx context.Context) error {
+		var err error
+		link, err = s.store.Update(ctx, key, now, fn)
+		return err
+	})
+	return link, err
+}
+
+func (s *instrumentedStore) Renew(ctx context.Context, key string, now, expiresAt time.Time) (Link, error) {
+	var link Link
+	err := s.observe(ctx, "renew", key, func(ctx context.Context) error {
+		var err error
+		link, err = s.store.Renew(ctx, key, now, expiresAt)
+		return err
+	})
+	return link, err
+}
+
+func (s *instrumentedStore) Delete(ctx context.Context, key string) error {
+	return s.observe(ctx, "delete", key, func(ctx context.Context) error {
+		return s.store.Delete(ctx, key)
+	})
+}
+
+// observe runs fn as the store operation op on key, which is left off the
+// span when empty.
+func (s *instrumentedStore) observe(ctx context.Context, op, key string, fn func(ctx context.Context) error) error {
+	attrs := []attribute.KeyValue{attribute.String("store.operation", op)}
+	if key != "" {
+		attrs = append(attrs, attribute.String("store.key", key))
+	}
+	ctx, span := s.tracer.Start(ctx, "store."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	inFlight := storeOperationsInFlight.WithLabelValues(op)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	timer := prometheus.NewTimer(storeOperationDuration.WithLabelValues(op))
+	err := fn(ctx)
+	timer.ObserveDuration()
+
+	if err != nil {
+		kind := storeErrorKind(err)
+		storeErrorsTotal.WithLabelValues(op, kind).Inc()
+		span.SetAttributes(attribute.String("store.error_kind", kind))
+		if kind == KindUnavailable {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+
+	return err
+}
+
+// storeErrorKind classifies an error returned directly by a Store. Errors
+// the store did not classify itself are backend failures.
+func storeErrorKind(err error) string {
+	kind := ErrorKind(err)
+	if kind == KindInternal {
+		return KindUnavailable
+	}
+	return kind
+}
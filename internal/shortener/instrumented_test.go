@@ -0,0 +1,138 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type stubStore struct {
+	getErr error
+}
+
+func (s stubStore) Set(context.Context, string, Link) error {
+	return nil
+}
+
+func (s stubStore) Create(context.Context, string, Link) error {
+	return nil
+}
+
+func (s stubStore) Resolve(context.Context, string, time.Time) (Link, error) {
+	return Link{URL: "https://example.com"}, s.getErr
+}
+
+func (s stubStore) CreateBatch(_ context.Context, entries []LinkEntry) ([]error, error) {
+	return make([]error, len(entries)), nil
+}
+
+func (s stubStore) Search(context.Context, LinkFilter, string, int) ([]LinkEntry, error) {
+	return nil, s.getErr
+}
+
+func (s stubStore) Scan(context.Context, string, int) ([]LinkEntry, string, error) {
+	return nil, "", s.getErr
+}
+
+func (s stubStore) Unlock(context.Context, string, time.Time) (Link, error) {
+	return Link{URL: "https://example.com"}, s.getErr
+}
+
+func (s stubStore) Get(context.Context, string) (Link, error) {
+	return Link{URL: "https://example.com"}, s.getErr
+}
+
+func (s stubStore) FindByURL(context.Context, string) (string, time.Time, error) {
+	return "", time.Time{}, ErrNotFound
+}
+
+func (s stubStore) FindByURLs(_ context.Context, urls []string) ([]URLMatch, error) {
+	matches := make([]URLMatch, len(urls))
+	for i := range matches {
+		matches[i].Err = ErrNotFound
+	}
+	return matches, nil
+}
+
+func (s stubStore) Delete(context.Context, string) error {
+	return s.getErr
+}
+
+func (s stubStore) Update(context.Context, string, time.Time, func(Link) (Link, error)) (Link, error) {
+	return Link{}, s.getErr
+}
+
+func (s stubStore) Renew(context.Context, string, time.Time, time.Time) (Link, error) {
+	return Link{}, s.getErr
+}
+
+func TestInstrumentedStore(t *testing.T) {
+	ctx := context.Background()
+	tracer := otel.Tracer("test")
+
+	t.Run("Records Latency", func(t *testing.T) {
+		store := newInstrumentedStore(stubStore{}, tracer)
+		before := observations(t, "get")
+
+		_, err := store.Get(ctx, "abc123")
+		assert.NoError(t, err)
+
+		assert.Equal(t, before+1, observations(t, "get"))
+		assert.Equal(t, 0.0, testutil.ToFloat64(storeOperationsInFlight.WithLabelValues("get")))
+	})
+
+	t.Run("Counts Errors By Kind", func(t *testing.T) {
+		notFound := testutil.ToFloat64(storeErrorsTotal.WithLabelValues("get", KindNotFound))
+		unavailable := testutil.ToFloat64(storeErrorsTotal.WithLabelValues("get", KindUnavailable))
+
+		_, err := newInstrumentedStore(stubStore{getErr: ErrNotFound}, tracer).Get(ctx, "abc123")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		backendErr := errors.New("connection refused")
+		_, err = newInstrumentedStore(stubStore{getErr: backendErr}, tracer).Get(ctx, "abc123")
+		assert.ErrorIs(t, err, backendErr)
+
+		assert.Equal(t, notFound+1, testutil.ToFloat64(storeErrorsTotal.WithLabelValues("get", KindNotFound)))
+		assert.Equal(t, unavailable+1, testutil.ToFloat64(storeErrorsTotal.WithLabelValues("get", KindUnavailable)))
+	})
+
+	t.Run("Keeps URLs Out Of Spans", func(t *testing.T) {
+		recorder := tracetest.NewSpanRecorder()
+		provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		store := newInstrumentedStore(stubStore{}, provider.Tracer("test"))
+
+		_, err := store.Get(ctx, "abc123")
+		assert.NoError(t, err)
+		_, _, err = store.FindByURL(ctx, "https://example.com/private?token=secret")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = store.FindByURLs(ctx, []string{"https://example.com/private?token=secret"})
+		assert.NoError(t, err)
+
+		spans := recorder.Ended()
+		if assert.Len(t, spans, 3) {
+			assert.Contains(t, spans[0].Attributes(), attribute.String("store.key", "abc123"))
+			for _, span := range spans[1:] {
+				for _, attr := range span.Attributes() {
+					assert.NotEqual(t, attribute.Key("store.key"), attr.Key, span.Name())
+				}
+			}
+		}
+	})
+}
+
+func observations(t *testing.T, op string) uint64 {
+	var m dto.Metric
+	err := storeOperationDuration.WithLabelValues(op).(prometheus.Histogram).Write(&m)
+	assert.NoError(t, err)
+	return m.GetHistogram().GetSampleCount()
+}
@@ -0,0 +1,49 @@
+package shortener
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	codeCollisionsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "short_code_collisions_total",
+			Help: "Total number of generated short codes that were already taken",
+		},
+	)
+	linksDeduplicatedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "links_deduplicated_total",
+			Help: "Total number of shorten requests answered with an existing link to the same URL",
+		},
+	)
+	passwordFailuresTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "link_password_failures_total",
+			Help: "Total number of incorrect passwords given for protected links",
+		},
+	)
+	storeOperationDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "store_operation_duration_seconds",
+			Help:    "Duration of link store operations",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"operation"},
+	)
+	storeErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "store_errors_total",
+			Help: "Total number of link store operations that returned an error, by error kind",
+		},
+		[]string{"operation", "kind"},
+	)
+	storeOperationsInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "store_operations_in_flight",
+			Help: "Number of link store operations currently running",
+		},
+		[]string{"operation"},
+	)
+)
@@ -0,0 +1,146 @@
+package shortener
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters for new password hashes, as recommended by OWASP.
+// Hashes record their own parameters, so changing these doesn't invalidate
+// existing ones.
+const (
+	argon2Time    = 2
+	argon2Memory  = 19 * 1024
+	argon2Threads = 1
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+
+	maxPasswordLength = 1024
+)
+
+// HashPassword hashes password with argon2id and a random salt, in the PHC
+// string format: $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword reports whether password matches a hash made by
+// HashPassword. Malformed hashes never match.
+func VerifyPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	other := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// maxTrackedCodes bounds how many codes an attemptLimiter tracks before it
+// sweeps windows that have ended.
+const maxTrackedCodes = 10000
+
+// attemptLimiter allows max failed password attempts per code in each
+// window. It is kept in memory, so every replica throttles on its own.
+type attemptLimiter struct {
+	max    int
+	window time.Duration
+
+	mu    sync.Mutex
+	codes map[string]attemptWindow
+}
+
+type attemptWindow struct {
+	failures int
+	resetAt  time.Time
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{max: max, window: window, codes: make(map[string]attemptWindow)}
+}
+
+// reserve counts an attempt at code against its window before the password
+// is checked, so concurrent guesses can't all slip in under the limit. It
+// returns how long code stays locked, zero if the attempt may go ahead.
+// Attempts that succeed are forgiven with reset, and those that never got
+// to check the password with release.
+func (l *attemptLimiter) reserve(code string, now time.Time) time.Duration {
+	if l.max <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.codes[code]
+	if !ok || !now.Before(w.resetAt) {
+		if len(l.codes) >= maxTrackedCodes {
+			l.sweep(now)
+		}
+		w = attemptWindow{resetAt: now.Add(l.window)}
+	}
+	if w.failures >= l.max {
+		return w.resetAt.Sub(now)
+	}
+	w.failures++
+	l.codes[code] = w
+	return 0
+}
+
+// release gives back an attempt reserved for code.
+func (l *attemptLimiter) release(code string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if w, ok := l.codes[code]; ok && w.failures > 0 {
+		w.failures--
+		l.codes[code] = w
+	}
+}
+
+// reset forgets the failures recorded for code.
+func (l *attemptLimiter) reset(code string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.codes, code)
+}
+
+// sweep must be called with l.mu held.
+func (l *attemptLimiter) sweep(now time.Time) {
+	for code, w := range l.codes {
+		if !now.Before(w.resetAt) {
+			delete(l.codes, code)
+		}
+	}
+}
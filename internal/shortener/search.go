@@ -0,0 +1,219 @@
+package shortener
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Limits on the metadata a link carries, and on the pages ListLinks
+// returns.
+const (
+	maxTags              = 10
+	maxTagLength         = 32
+	maxTitleLength       = 200
+	maxDescriptionLength = 1000
+
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// LinkFilter selects links for ListLinks and Store.Search. Zero fields
+// match every link. ListLinks lowercases Tag, Domain and Text before
+// handing the filter to the store.
+type LinkFilter struct {
+	// Tag matches links carrying it.
+	Tag string
+	// Domain matches links whose destination host is exactly Domain.
+	Domain  string
+	Creator string
+	// CreatedFrom and CreatedTo bound when the link was created, CreatedTo
+	// excluded. Links stored before creation times were recorded never
+	// match a bound.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Text matches links whose URL, title or description contains it,
+	// ignoring case.
+	Text string
+}
+
+// Matches reports whether link passes the filter, for stores that filter
+// links themselves rather than through an index.
+func (f LinkFilter) Matches(link Link) bool {
+	switch {
+	case f.Tag != "" && !slices.Contains(link.Tags, f.Tag):
+		return false
+	case f.Domain != "" && link.Domain() != f.Domain:
+		return false
+	case f.Creator != "" && link.Creator != f.Creator:
+		return false
+	case !f.CreatedFrom.IsZero() && (link.CreatedAt.IsZero() || link.CreatedAt.Before(f.CreatedFrom)):
+		return false
+	case !f.CreatedTo.IsZero() && (link.CreatedAt.IsZero() || !link.CreatedAt.Before(f.CreatedTo)):
+		return false
+	case f.Text != "":
+		// A protected link's destination is only for those who know the
+		// password, so searching it must not reveal it either.
+		return (link.PasswordHash == "" && strings.Contains(strings.ToLower(link.URL), f.Text)) ||
+			strings.Contains(strings.ToLower(link.Title), f.Text) ||
+			strings.Contains(strings.ToLower(link.Description), f.Text)
+	}
+	return true
+}
+
+// Domain returns the host of the link's destination, without a port.
+func (l Link) Domain() string {
+	u, err := url.Parse(l.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// labeled reports whether the link was given a title, description or tags.
+// Shortening a URL with any of them always creates a new link, so they
+// aren't lost on a link someone else created.
+func (l Link) labeled() bool {
+	return l.Title != "" || l.Description != "" || len(l.Tags) > 0
+}
+
+// LinkPage is one page of ListLinks results. Cursor resumes the listing
+// after the page; it is empty once there is nothing left.
+type LinkPage struct {
+	Links  []LinkInfo
+	Cursor string
+}
+
+// ListLinks returns the live links matching filter, in code order, limit
+// at a time. A zero limit means the default page size, and larger limits
+// are capped. cursor is empty for the first page and the Cursor of the
+// previous page after that. Links that are stored but no longer redirect,
+// having used up their clicks or passed the end of their window, are left
+// out, so a page may hold fewer than limit links and still have a Cursor.
+func (s *Service) ListLinks(ctx context.Context, filter LinkFilter, cursor string, limit int) (LinkPage, error) {
+	ctx, span := s.tracer.Start(ctx, "ListLinks")
+	defer span.End()
+
+	switch {
+	case limit < 0:
+		return LinkPage{}, InvalidFilterError{Reason: "limit must be positive"}
+	case limit == 0:
+		limit = defaultPageSize
+	case limit > maxPageSize:
+		limit = maxPageSize
+	}
+	after, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return LinkPage{}, InvalidFilterError{Reason: "malformed cursor"}
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedTo.After(filter.CreatedFrom) {
+		return LinkPage{}, InvalidFilterError{Reason: "the end of the creation range must be later than its start"}
+	}
+	filter.Tag = strings.ToLower(strings.TrimSpace(filter.Tag))
+	filter.Domain = strings.ToLower(strings.TrimSpace(filter.Domain))
+	filter.Text = strings.ToLower(strings.TrimSpace(filter.Text))
+
+	// One more link than asked for tells whether there is another page.
+	entries, err := s.store.Search(ctx, filter, string(after), limit+1)
+	if err != nil {
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "search", Err: err}
+		}
+		return LinkPage{}, fmt.Errorf("failed to list links: %w", err)
+	}
+
+	var page LinkPage
+	if len(entries) > limit {
+		entries = entries[:limit]
+		page.Cursor = base64.RawURLEncoding.EncodeToString([]byte(entries[limit-1].Key))
+	}
+	now := s.now()
+	page.Links = make([]LinkInfo, 0, len(entries))
+	for _, entry := range entries {
+		if info := newLinkInfo(entry.Key, entry.Link, now); info.Status != StatusExpired {
+			page.Links = append(page.Links, info)
+		}
+	}
+	return page, nil
+}
+
+// ScanLinks lists every live link the store holds, about limit at a time,
+// with the same limits as ListLinks. Unlike ListLinks the links come in no
+// particular order, but the store is walked without the help of any index,
+// so it lists links that no index knows about.
+func (s *Service) ScanLinks(ctx context.Context, cursor string, limit int) (LinkPage, error) {
+	ctx, span := s.tracer.Start(ctx, "ScanLinks")
+	defer span.End()
+
+	switch {
+	case limit < 0:
+		return LinkPage{}, InvalidFilterError{Reason: "limit must be positive"}
+	case limit == 0:
+		limit = defaultPageSize
+	case limit > maxPageSize:
+		limit = maxPageSize
+	}
+	position, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return LinkPage{}, InvalidFilterError{Reason: "malformed cursor"}
+	}
+
+	entries, next, err := s.store.Scan(ctx, string(position), limit)
+	if err != nil {
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "scan", Err: err}
+		}
+		return LinkPage{}, fmt.Errorf("failed to scan links: %w", err)
+	}
+
+	page := LinkPage{Links: make([]LinkInfo, len(entries))}
+	if next != "" {
+		page.Cursor = base64.RawURLEncoding.EncodeToString([]byte(next))
+	}
+	now := s.now()
+	for i, entry := range entries {
+		page.Links[i] = newLinkInfo(entry.Key, entry.Link, now)
+	}
+	return page, nil
+}
+
+// normalizeTags lowercases tags, drops duplicates and sorts them. Tags may
+// hold letters, digits, '-', '_' and '.'.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	if len(tags) > maxTags {
+		return nil, InvalidMetadataError{Reason: fmt.Sprintf("a link may have at most %d tags", maxTags)}
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > maxTagLength {
+			return nil, InvalidMetadataError{Reason: fmt.Sprintf("tags must be 1 to %d characters long", maxTagLength)}
+		}
+		for _, r := range tag {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' && r != '.' {
+				return nil, InvalidMetadataError{Reason: fmt.Sprintf("tag %q may only hold letters, digits, '-', '_' and '.'", tag)}
+			}
+		}
+		normalized = append(normalized, tag)
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
+
+func validateText(title, description string) error {
+	if len(title) > maxTitleLength {
+		return InvalidMetadataError{Reason: fmt.Sprintf("title may be at most %d bytes", maxTitleLength)}
+	}
+	if len(description) > maxDescriptionLength {
+		return InvalidMetadataError{Reason: fmt.Sprintf("description may be at most %d bytes", maxDescriptionLength)}
+	}
+	return nil
+}
@@ -2,81 +2,1102 @@ package shortener
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// Link is a stored short link.
+type Link struct {
+	URL string
+	// CreatedAt is when the link was shortened, and Creator who asked for
+	// it. Both are zero for links stored before they were recorded.
+	CreatedAt time.Time
+	Creator   string
+	// Title, Description and Tags help find the link again; Tags are
+	// lowercase and sorted.
+	Title       string
+	Description string
+	Tags        []string
+	// ExpiresAt is zero for a link that never expires.
+	ExpiresAt time.Time
+	// Slide makes the expiration sliding: each redirect pushes ExpiresAt
+	// out to Slide from the time of the click, but never past
+	// MaxExpiresAt. Zero keeps the expiration fixed.
+	Slide        time.Duration
+	MaxExpiresAt time.Time
+	// MaxClicks is how many redirects the link serves, zero meaning no
+	// limit. Clicks counts the redirects served so far.
+	MaxClicks int64
+	Clicks    int64
+	// NotBefore and NotAfter bound when the link redirects; zero times
+	// leave that side of the window open.
+	NotBefore time.Time
+	NotAfter  time.Time
+	// RedirectStatus is the HTTP status the link redirects with, one of
+	// the RedirectStatuses. Zero leaves the choice to the server.
+	RedirectStatus int
+	// Version counts the updates made to the link since it was created.
+	Version int64
+	// Disabled marks the tombstone of a deleted link.
+	Disabled bool
+	// PasswordHash, made by HashPassword, protects the link: it is only
+	// followed once the visitor gives the password.
+	PasswordHash string
+	// History lists the destinations the link has been repointed away
+	// from, oldest first.
+	History []HistoryEntry
+}
+
+// HistoryEntry records a change of a link's destination: the URL the link
+// had at Version, and who replaced it, when and why.
+type HistoryEntry struct {
+	Version   int64
+	URL       string
+	Actor     string
+	ChangedAt time.Time
+	Reason    string
+}
+
+// ETag identifies the link's current version for conditional updates.
+func (l Link) ETag() string {
+	sum := sha256.Sum256([]byte(strconv.FormatInt(l.Version, 10) + "\x00" + l.URL))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// Restricted reports whether the link has a click limit, an activation
+// window, a sliding expiration or a password. Restricted links are never
+// shared between shorten requests.
+func (l Link) Restricted() bool {
+	return l.MaxClicks > 0 || !l.NotBefore.IsZero() || !l.NotAfter.IsZero() || l.Slide > 0 || l.PasswordHash != ""
+}
+
+// Shareable reports whether the link can be handed out to shorten requests
+// for its URL: it is not restricted and redirects with the server's default
+// status. Stores only find shareable links by URL.
+func (l Link) Shareable() bool {
+	return !l.Restricted() && l.RedirectStatus == 0
+}
+
+// Renewal returns the expiration the link gets when renewed until
+// expiresAt: never earlier than its current one, nor later than
+// MaxExpiresAt. A link that never expires keeps never expiring.
+func (l Link) Renewal(expiresAt time.Time) time.Time {
+	if l.ExpiresAt.IsZero() {
+		return l.ExpiresAt
+	}
+	if !l.MaxExpiresAt.IsZero() && expiresAt.After(l.MaxExpiresAt) {
+		expiresAt = l.MaxExpiresAt
+	}
+	if expiresAt.After(l.ExpiresAt) {
+		return expiresAt
+	}
+	return l.ExpiresAt
+}
+
+// Active checks now against the link's activation window. It returns
+// ErrNotYetActive before NotBefore and ErrExpired from NotAfter on.
+func (l Link) Active(now time.Time) error {
+	if !l.NotBefore.IsZero() && now.Before(l.NotBefore) {
+		return ErrNotYetActive
+	}
+	if !l.NotAfter.IsZero() && !now.Before(l.NotAfter) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Store persists short code to links. Reads must return ErrNotFound (or
+// ErrExpired) when there is no live link for the key; any other error is
+// treated as a backend failure.
 type Store interface {
-	Set(ctx context.Context, key, value string, expiration time.Duration) error
-	Get(ctx context.Context, key string) (string, error)
+	// Set stores link at key, replacing whatever the key held.
+	Set(ctx context.Context, key string, link Link) error
+	// Create stores link only if key does not hold a live link, and returns
+	// ErrAlreadyExists otherwise. The check and the write must be atomic.
+	Create(ctx context.Context, key string, link Link) error
+	// CreateBatch creates each entry as Create would, in order, in as few
+	// round trips as the backend allows. It returns one error per entry,
+	// and an entry failing doesn't stop the others. An error for the call
+	// as a whole means any of the entries may or may not have been written.
+	CreateBatch(ctx context.Context, entries []LinkEntry) ([]error, error)
+	// Get returns the link at key without following it, so no click is
+	// counted. Links that have expired but are still stored, and
+	// tombstones, are returned as they are; only a key with no link at all
+	// returns ErrNotFound.
+	Get(ctx context.Context, key string) (Link, error)
+	// Resolve reads a link in order to follow it at time now. It returns
+	// ErrNotYetActive before the link's NotBefore and ErrExpired from its
+	// NotAfter on. Inside the window it counts the click, or for a link
+	// with MaxClicks returns ErrExhausted once MaxClicks redirects have been
+	// served; the check and the increment must be atomic. A sliding link is
+	// renewed until Slide after now in the same step. A password-protected
+	// link that would otherwise be served returns ErrPasswordRequired, and
+	// no click is counted.
+	Resolve(ctx context.Context, key string, now time.Time) (Link, error)
+	// Unlock follows a password-protected link, once the caller has checked
+	// the password, exactly as Resolve follows any other link.
+	Unlock(ctx context.Context, key string, now time.Time) (Link, error)
+	// FindByURL returns the key of a live link whose value is url and its
+	// expiration (zero if it has none), or ErrNotFound. When several keys
+	// hold url, any of them may be returned. Restricted and disabled links
+	// are never returned.
+	FindByURL(ctx context.Context, url string) (string, time.Time, error)
+	// FindByURLs looks up each of urls as FindByURL would, in as few round
+	// trips as the backend allows. It returns one URLMatch per URL, in
+	// order. An error for the call as a whole means none were looked up.
+	FindByURLs(ctx context.Context, urls []string) ([]URLMatch, error)
+	// Update replaces the URL, expiration and history of the link at key,
+	// if it is live at time now, with those of the link fn returns for it,
+	// and increments its Version. It returns the stored link, ErrNotFound
+	// or ErrExpired, or fn's error. The read and the write must be atomic;
+	// stores may call fn again if the link changed in between.
+	Update(ctx context.Context, key string, now time.Time, fn func(current Link) (Link, error)) (Link, error)
+	// Renew pushes the expiration of the link at key, if it is live at time
+	// now, out to expiresAt, as bounded by Link.Renewal, without changing
+	// its Version. It returns the stored link, ErrNotFound, ErrExpired or
+	// ErrDisabled.
+	Renew(ctx context.Context, key string, now, expiresAt time.Time) (Link, error)
+	// Delete replaces the link at key with a tombstone that never expires,
+	// so the key can't be created again. Resolving a tombstone returns
+	// ErrDisabled. It returns ErrNotFound if there is no link at key, and
+	// nil for a link that is already deleted.
+	Delete(ctx context.Context, key string) error
+	// Search returns up to limit live links that match filter and whose
+	// key sorts after after, in key order. Expired links and tombstones
+	// are never returned.
+	Search(ctx context.Context, filter LinkFilter, after string, limit int) ([]LinkEntry, error)
+	// Scan lists every live link, about limit at a time and in no
+	// particular order. cursor is empty to start and the cursor returned
+	// by the previous call after that; an empty cursor is returned once
+	// the scan is done. Links stored for the whole scan are returned at
+	// least once, but may be returned more than once.
+	Scan(ctx context.Context, cursor string, limit int) ([]LinkEntry, string, error)
+}
+
+// LinkEntry is a link stored at Key, as written by Store.CreateBatch and
+// listed by Store.Search.
+type LinkEntry struct {
+	Key  string
+	Link Link
+}
+
+// URLMatch is what Store.FindByURLs found for one URL: the key and
+// expiration FindByURL would have returned, or its error.
+type URLMatch struct {
+	Key       string
+	ExpiresAt time.Time
+	Err       error
+}
+
+// Iterator is implemented by stores that can enumerate their links, for
+// export and backups. Iterate calls fn once per live link and once per
+// tombstone, so a backup keeps deleted codes reserved. An error returned
+// by fn stops the iteration and is returned from Iterate. fn must not call
+// back into the store.
+type Iterator interface {
+	Iterate(ctx context.Context, fn func(key string, link Link) error) error
+}
+
+// ShortenOptions customizes ShortenURL. The zero value generates a code.
+type ShortenOptions struct {
+	// Alias claims a specific code instead of a generated one. The call
+	// fails with AliasTakenError if it already holds a live link.
+	Alias string
+	// ForceNew skips deduplication and always creates a new code, even if a
+	// live link to the same URL exists.
+	ForceNew bool
+
+	// At most one of TTL, ExpiresAt and Permanent may be set. Without any of
+	// them the link gets the service's default TTL.
+	TTL       time.Duration
+	ExpiresAt time.Time
+	Permanent bool
+
+	// MaxClicks limits how many redirects the link serves, and NotBefore
+	// and NotAfter when it redirects. Such links are never deduplicated.
+	MaxClicks int64
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	// Password protects the link; it is stored hashed. Protected links are
+	// never deduplicated.
+	Password string
+
+	// Sliding extends the link's lifetime with every redirect, up to the
+	// service's ceiling. Without a TTL or ExpiresAt, a sliding link starts
+	// out with one slide to live. Sliding links are never deduplicated
+	// either.
+	Sliding bool
+
+	// Creator records who asked for the link, for GetLink.
+	Creator string
+
+	// RedirectStatus is the status the link redirects with, zero for the
+	// server's default. Links with different statuses are never shared.
+	RedirectStatus int
+
+	// Title, Description and Tags are kept with the link for ListLinks to
+	// find it by.
+	Title       string
+	Description string
+	Tags        []string
+}
+
+// ShortenResult describes the link ShortenURL created or reused.
+type ShortenResult struct {
+	Code string
+	// ExpiresAt is zero for a link that never expires.
+	ExpiresAt time.Time
+}
+
+// UpdateOptions changes an existing link. An empty URL keeps the current
+// destination, and the link keeps its remaining lifetime unless TTL,
+// ExpiresAt or Permanent is given.
+type UpdateOptions struct {
+	URL       string
+	TTL       time.Duration
+	ExpiresAt time.Time
+	Permanent bool
+	// IfMatch is an If-Match header value. Unless it is empty or "*", the
+	// update only applies if it lists the link's current ETag.
+	IfMatch string
+	// Actor and Reason are recorded in the link's history when the
+	// destination changes.
+	Actor  string
+	Reason string
+	// RedirectStatus, if set, replaces the link's redirect status; zero
+	// hands the choice back to the server.
+	RedirectStatus *int
+}
+
+// RollbackOptions selects the destination RollbackLink restores. Without a
+// Version it restores the destination the link had before its last change.
+type RollbackOptions struct {
+	Version *int64
+	IfMatch string
+	Actor   string
+	Reason  string
+}
+
+// RenewOptions extends a link's lifetime. A zero TTL renews a sliding link
+// by its Slide and any other link by the service's default TTL.
+type RenewOptions struct {
+	TTL time.Duration
+}
+
+// UpdateResult describes a link after UpdateLink.
+type UpdateResult struct {
+	URL            string
+	ExpiresAt      time.Time
+	RedirectStatus int
+	ETag           string
+}
+
+// Redirect is where FollowLink and UnlockLink send a visitor, and with
+// which status; a zero Status leaves the choice to the server.
+type Redirect struct {
+	URL    string
+	Status int
+}
+
+// LinkStatus summarizes whether a link still redirects.
+type LinkStatus string
+
+const (
+	StatusActive   LinkStatus = "active"
+	StatusExpired  LinkStatus = "expired"
+	StatusDisabled LinkStatus = "disabled"
+)
+
+// LinkInfo describes a link as returned by GetLink.
+type LinkInfo struct {
+	Code        string
+	URL         string
+	Title       string
+	Description string
+	Tags        []string
+	CreatedAt   time.Time
+	// ExpiresAt is zero for a link that never expires, and TTL is the time
+	// left until then, zero once it has passed or if there is none.
+	ExpiresAt time.Time
+	TTL       time.Duration
+	// Slide and MaxExpiresAt are set for a sliding link.
+	Slide        time.Duration
+	MaxExpiresAt time.Time
+	Clicks       int64
+	MaxClicks    int64
+	Creator      string
+	Protected    bool
+	// RedirectStatus is zero for a link that redirects with the server's
+	// default status.
+	RedirectStatus int
+	Status         LinkStatus
+	ETag           string
 }
 
 type Shortener interface {
-	ShortenURL(ctx context.Context, longURL string) (string, error)
-	GetLongURL(ctx context.Context, shortCode string) (string, error)
+	ShortenURL(ctx context.Context, longURL string, opts ShortenOptions) (ShortenResult, error)
+	ShortenBatch(ctx context.Context, items []BatchItem) ([]BatchResult, error)
+	FollowLink(ctx context.Context, shortCode string) (Redirect, error)
+	UnlockLink(ctx context.Context, shortCode, password string) (Redirect, error)
+	GetLink(ctx context.Context, shortCode string) (LinkInfo, error)
+	ListLinks(ctx context.Context, filter LinkFilter, cursor string, limit int) (LinkPage, error)
+	ScanLinks(ctx context.Context, cursor string, limit int) (LinkPage, error)
+	UpdateLink(ctx context.Context, shortCode string, opts UpdateOptions) (UpdateResult, error)
+	LinkHistory(ctx context.Context, shortCode string) ([]HistoryEntry, error)
+	RollbackLink(ctx context.Context, shortCode string, opts RollbackOptions) (UpdateResult, error)
+	RenewLink(ctx context.Context, shortCode string, opts RenewOptions) (UpdateResult, error)
+	DeleteLink(ctx context.Context, shortCode string) error
 }
 
+const (
+	defaultMaxAttempts = 5
+	defaultCodeLength  = 6
+	defaultTTL         = 24 * time.Hour
+	defaultHistorySize = 20
+	defaultSlide       = 24 * time.Hour
+	defaultMaxSlide    = 30 * 24 * time.Hour
+	defaultBatchLimit  = 500
+
+	defaultPasswordAttempts = 5
+	defaultPasswordLockout  = 15 * time.Minute
+)
+
 type Service struct {
-	store  Store
-	tracer trace.Tracer
+	store       Store
+	tracer      trace.Tracer
+	maxAttempts int
+	codes       CodeGenerator
+	defaultTTL  time.Duration
+	maxTTL      time.Duration
+	historySize int
+	slide       time.Duration
+	maxSlide    time.Duration
+	attempts    *attemptLimiter
+	batchLimit  int
+	now         func() time.Time
+}
+
+type Option func(*Service)
+
+// WithMaxAttempts sets how many short codes ShortenURL generates before
+// giving up when every candidate is already taken.
+func WithMaxAttempts(n int) Option {
+	return func(s *Service) {
+		if n > 0 {
+			s.maxAttempts = n
+		}
+	}
+}
+
+// WithCodeGenerator replaces the default generator, which draws six random
+// base62 characters.
+func WithCodeGenerator(g CodeGenerator) Option {
+	return func(s *Service) {
+		if g != nil {
+			s.codes = g
+		}
+	}
 }
 
-func NewService(store Store) *Service {
-	return &Service{
-		store:  store,
-		tracer: otel.Tracer("shrink-service"),
+// WithDefaultTTL sets the lifetime of links created without a TTL,
+// expiration time or the permanent flag. Zero makes them permanent.
+func WithDefaultTTL(d time.Duration) Option {
+	return func(s *Service) {
+		if d >= 0 {
+			s.defaultTTL = d
+		}
 	}
 }
 
-func (s *Service) ShortenURL(ctx context.Context, longURL string) (string, error) {
+// WithMaxTTL caps the lifetime a link may be given. Permanent links are
+// refused while a cap is set. Zero, the default, means no cap.
+func WithMaxTTL(d time.Duration) Option {
+	return func(s *Service) {
+		if d >= 0 {
+			s.maxTTL = d
+		}
+	}
+}
+
+// WithHistorySize sets how many past destinations a link's history keeps.
+// Older entries are dropped as new ones are added; zero keeps no history.
+func WithHistorySize(n int) Option {
+	return func(s *Service) {
+		if n >= 0 {
+			s.historySize = n
+		}
+	}
+}
+
+// WithSlidingExpiration sets how far each redirect pushes out the
+// expiration of a sliding link, and how long after its creation the link
+// expires regardless. A zero slide turns sliding links off.
+func WithSlidingExpiration(slide, max time.Duration) Option {
+	return func(s *Service) {
+		if slide >= 0 && max >= slide {
+			s.slide = slide
+			s.maxSlide = max
+		}
+	}
+}
+
+// WithPasswordAttempts allows max incorrect passwords per code before the
+// code is locked for the rest of the lockout window, which starts with the
+// first failure. Zero turns throttling off.
+func WithPasswordAttempts(max int, lockout time.Duration) Option {
+	return func(s *Service) {
+		if max >= 0 && lockout > 0 {
+			s.attempts = newAttemptLimiter(max, lockout)
+		}
+	}
+}
+
+// WithBatchLimit sets how many items ShortenBatch takes at once.
+func WithBatchLimit(n int) Option {
+	return func(s *Service) {
+		if n > 0 {
+			s.batchLimit = n
+		}
+	}
+}
+
+// WithClock replaces time.Now as the service's source of the current time.
+func WithClock(now func() time.Time) Option {
+	return func(s *Service) {
+		if now != nil {
+			s.now = now
+		}
+	}
+}
+
+func NewService(store Store, opts ...Option) *Service {
+	tracer := otel.Tracer("shrink-service")
+	s := &Service{
+		store:       newInstrumentedStore(store, tracer),
+		tracer:      tracer,
+		maxAttempts: defaultMaxAttempts,
+		codes:       &RandomGenerator{alphabet: Base62Alphabet, length: defaultCodeLength},
+		defaultTTL:  defaultTTL,
+		historySize: defaultHistorySize,
+		slide:       defaultSlide,
+		maxSlide:    defaultMaxSlide,
+		attempts:    newAttemptLimiter(defaultPasswordAttempts, defaultPasswordLockout),
+		batchLimit:  defaultBatchLimit,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Service) ShortenURL(ctx context.Context, longURL string, opts ShortenOptions) (ShortenResult, error) {
 	ctx, span := s.tracer.Start(ctx, "ShortenURL")
 	defer span.End()
 
-	parsedURL, err := url.Parse(longURL)
+	link, err := s.newLink(longURL, opts, s.now())
 	if err != nil {
-		return "", InvalidURLError{Reason: err.Error()}
+		return ShortenResult{}, err
 	}
-	if parsedURL.Scheme == "" || parsedURL.Host == "" {
-		return "", InvalidURLError{Reason: "missing scheme or host"}
+
+	if opts.Alias != "" {
+		return s.claimAlias(ctx, opts.Alias, link)
+	}
+
+	if !opts.ForceNew && link.Shareable() && !link.labeled() {
+		existing, ok, err := s.findExisting(ctx, link, opts)
+		if err != nil {
+			return ShortenResult{}, err
+		}
+		if ok {
+			span.AddEvent("deduplicated")
+			return existing, nil
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		shortCode, err := s.codes.Generate(ctx)
+		if err != nil {
+			return ShortenResult{}, fmt.Errorf("failed to generate short code: %w", err)
+		}
+
+		if reserved(shortCode) {
+			span.AddEvent("reserved short code skipped")
+		} else {
+			err = s.store.Create(ctx, shortCode, link)
+			if err == nil {
+				return ShortenResult{Code: shortCode, ExpiresAt: link.ExpiresAt}, nil
+			}
+			if !errors.Is(err, ErrAlreadyExists) {
+				return ShortenResult{}, fmt.Errorf("failed to store URL: %w", StorageError{Op: "create", Err: err})
+			}
+
+			codeCollisionsTotal.Inc()
+			span.AddEvent("short code collision")
+		}
+
+		if attempt >= s.maxAttempts {
+			return ShortenResult{}, fmt.Errorf("no free short code after %d attempts: %w", attempt, ErrCodeSpaceExhausted)
+		}
+	}
+}
+
+// newLink validates a request to shorten longURL and builds the link to
+// store, created at now.
+func (s *Service) newLink(longURL string, opts ShortenOptions, now time.Time) (Link, error) {
+	longURL, err := parseURL(longURL)
+	if err != nil {
+		return Link{}, err
+	}
+
+	var ttl time.Duration
+	if opts.Sliding && !opts.hasExpiration() {
+		ttl = s.slide
+	} else if ttl, err = s.ttl(opts, now); err != nil {
+		return Link{}, err
+	}
+	if opts.MaxClicks < 0 {
+		return Link{}, InvalidClickLimitError{Reason: "maxClicks must be positive"}
+	}
+	if !opts.NotBefore.IsZero() && !opts.NotAfter.IsZero() && !opts.NotAfter.After(opts.NotBefore) {
+		return Link{}, InvalidWindowError{Reason: "notAfter must be later than notBefore"}
+	}
+	if !opts.NotAfter.IsZero() && !opts.NotAfter.After(now) {
+		return Link{}, InvalidWindowError{Reason: "notAfter is in the past"}
+	}
+	if len(opts.Password) > maxPasswordLength {
+		return Link{}, InvalidPasswordError{Reason: fmt.Sprintf("may be at most %d bytes", maxPasswordLength)}
+	}
+	if opts.Alias != "" {
+		if err := validateAlias(opts.Alias); err != nil {
+			return Link{}, err
+		}
+	}
+	tags, err := normalizeTags(opts.Tags)
+	if err != nil {
+		return Link{}, err
+	}
+	if err := validateText(opts.Title, opts.Description); err != nil {
+		return Link{}, err
+	}
+	if err := validateRedirectStatus(opts.RedirectStatus); err != nil {
+		return Link{}, err
+	}
+
+	link := Link{
+		URL:            longURL,
+		CreatedAt:      now,
+		Creator:        opts.Creator,
+		Title:          opts.Title,
+		Description:    opts.Description,
+		Tags:           tags,
+		MaxClicks:      opts.MaxClicks,
+		NotBefore:      opts.NotBefore,
+		NotAfter:       opts.NotAfter,
+		RedirectStatus: opts.RedirectStatus,
+	}
+	if opts.Password != "" {
+		if link.PasswordHash, err = HashPassword(opts.Password); err != nil {
+			return Link{}, err
+		}
+	}
+	if opts.Sliding {
+		switch {
+		case s.slide == 0:
+			return Link{}, InvalidExpirationError{Reason: "sliding expiration is disabled"}
+		case opts.Permanent:
+			return Link{}, InvalidExpirationError{Reason: "a sliding link can't be permanent"}
+		}
+		ceiling := s.maxSlide
+		if s.maxTTL > 0 {
+			ceiling = min(ceiling, s.maxTTL)
+		}
+		ttl = min(ttl, ceiling)
+		link.Slide = s.slide
+		link.MaxExpiresAt = now.Add(ceiling)
 	}
+	if ttl > 0 {
+		link.ExpiresAt = now.Add(ttl)
+	}
+
+	return link, nil
+}
+
+// findExisting looks for a live link to the same URL that can be handed out
+// instead of creating link. Deduplication is best effort: two concurrent
+// requests for the same URL can still create two codes.
+func (s *Service) findExisting(ctx context.Context, link Link, opts ShortenOptions) (ShortenResult, bool, error) {
+	var match URLMatch
+	match.Key, match.ExpiresAt, match.Err = s.store.FindByURL(ctx, link.URL)
+	return reuseExisting(match, link, opts)
+}
+
+// reuseExisting decides whether match, the store's lookup of link's URL,
+// can be handed out instead of link. When the caller asked for a lifetime,
+// a link is only reused if it lives at least that long.
+func reuseExisting(match URLMatch, link Link, opts ShortenOptions) (ShortenResult, bool, error) {
+	switch err := match.Err; {
+	case err == nil && (!opts.hasExpiration() || outlives(match.ExpiresAt, link.ExpiresAt)):
+		linksDeduplicatedTotal.Inc()
+		return ShortenResult{Code: match.Key, ExpiresAt: match.ExpiresAt}, true, nil
+	case err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrExpired):
+		return ShortenResult{}, false, fmt.Errorf("failed to look up URL: %w", StorageError{Op: "find", Err: err})
+	}
+	return ShortenResult{}, false, nil
+}
+
+func (o ShortenOptions) hasExpiration() bool {
+	return o.TTL != 0 || !o.ExpiresAt.IsZero() || o.Permanent
+}
+
+// outlives reports whether a link expiring at a lives at least as long as
+// one expiring at b. The zero time means never.
+func outlives(a, b time.Time) bool {
+	return a.IsZero() || !b.IsZero() && !a.Before(b)
+}
+
+// ttl resolves the lifetime requested by opts, zero meaning permanent.
+func (s *Service) ttl(opts ShortenOptions, now time.Time) (time.Duration, error) {
+	set := 0
+	for _, ok := range []bool{opts.TTL != 0, !opts.ExpiresAt.IsZero(), opts.Permanent} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return 0, InvalidExpirationError{Reason: "only one of ttl, expiresAt and permanent may be given"}
+	}
+
+	var ttl time.Duration
+	switch {
+	case opts.Permanent:
+		ttl = 0
+	case opts.TTL < 0:
+		return 0, InvalidExpirationError{Reason: "ttl must be positive"}
+	case opts.TTL > 0:
+		ttl = opts.TTL
+	case !opts.ExpiresAt.IsZero():
+		ttl = opts.ExpiresAt.Sub(now)
+		if ttl <= 0 {
+			return 0, InvalidExpirationError{Reason: "expiresAt is in the past"}
+		}
+	default:
+		ttl = s.defaultTTL
+	}
+
+	if s.maxTTL > 0 && (ttl == 0 || ttl > s.maxTTL) {
+		return 0, InvalidExpirationError{Reason: fmt.Sprintf("links may live at most %s", s.maxTTL)}
+	}
+	return ttl, nil
+}
+
+func (s *Service) claimAlias(ctx context.Context, alias string, link Link) (ShortenResult, error) {
+	err := s.store.Create(ctx, alias, link)
+	if errors.Is(err, ErrAlreadyExists) {
+		return ShortenResult{}, AliasTakenError{Alias: alias}
+	}
+	if err != nil {
+		return ShortenResult{}, fmt.Errorf("failed to store URL: %w", StorageError{Op: "create", Err: err})
+	}
+
+	return ShortenResult{Code: alias, ExpiresAt: link.ExpiresAt}, nil
+}
+
+// FollowLink counts a click on a link and returns where it redirects.
+func (s *Service) FollowLink(ctx context.Context, shortCode string) (Redirect, error) {
+	ctx, span := s.tracer.Start(ctx, "FollowLink")
+	defer span.End()
+
+	link, err := s.store.Resolve(ctx, shortCode, s.now())
+	if err != nil {
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "resolve", Err: err}
+		}
+		return Redirect{}, fmt.Errorf("failed to retrieve long URL: %w", err)
+	}
+
+	return Redirect{URL: link.URL, Status: link.RedirectStatus}, nil
+}
+
+// UnlockLink follows a password-protected link once password matches it,
+// counting the click as FollowLink does. Each code allows only a few
+// incorrect passwords per lockout window; after that it returns
+// TooManyAttemptsError until the window ends. Links without a password are
+// followed whatever the password.
+func (s *Service) UnlockLink(ctx context.Context, shortCode, password string) (Redirect, error) {
+	ctx, span := s.tracer.Start(ctx, "UnlockLink")
+	defer span.End()
+
+	now := s.now()
+	if wait := s.attempts.reserve(shortCode, now); wait > 0 {
+		return Redirect{}, TooManyAttemptsError{RetryAfter: wait}
+	}
+
+	link, err := s.store.Get(ctx, shortCode)
+	if err != nil {
+		s.attempts.release(shortCode)
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "get", Err: err}
+		}
+		return Redirect{}, fmt.Errorf("failed to unlock link: %w", err)
+	}
+	if link.PasswordHash != "" && !VerifyPassword(link.PasswordHash, password) {
+		passwordFailuresTotal.Inc()
+		return Redirect{}, ErrIncorrectPassword
+	}
+	s.attempts.reset(shortCode)
+
+	link, err = s.store.Unlock(ctx, shortCode, now)
+	if err != nil {
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "unlock", Err: err}
+		}
+		return Redirect{}, fmt.Errorf("failed to unlock link: %w", err)
+	}
+
+	return Redirect{URL: link.URL, Status: link.RedirectStatus}, nil
+}
+
+// GetLink returns a link's details without following it. Unlike
+// FollowLink it also describes links that have expired or been deleted, as
+// long as the store still holds them.
+func (s *Service) GetLink(ctx context.Context, shortCode string) (LinkInfo, error) {
+	ctx, span := s.tracer.Start(ctx, "GetLink")
+	defer span.End()
+
+	link, err := s.store.Get(ctx, shortCode)
+	if err != nil {
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "get", Err: err}
+		}
+		return LinkInfo{}, fmt.Errorf("failed to get link: %w", err)
+	}
+
+	return newLinkInfo(shortCode, link, s.now()), nil
+}
+
+// newLinkInfo describes the link at code as of now.
+func newLinkInfo(code string, link Link, now time.Time) LinkInfo {
+	info := LinkInfo{
+		Code:           code,
+		URL:            link.URL,
+		Title:          link.Title,
+		Description:    link.Description,
+		Tags:           link.Tags,
+		CreatedAt:      link.CreatedAt,
+		ExpiresAt:      link.ExpiresAt,
+		Slide:          link.Slide,
+		MaxExpiresAt:   link.MaxExpiresAt,
+		Clicks:         link.Clicks,
+		MaxClicks:      link.MaxClicks,
+		Creator:        link.Creator,
+		Protected:      link.PasswordHash != "",
+		Status:         linkStatus(link, now),
+		ETag:           link.ETag(),
+		RedirectStatus: link.RedirectStatus,
+	}
+	if !link.ExpiresAt.IsZero() && link.ExpiresAt.After(now) {
+		info.TTL = link.ExpiresAt.Sub(now)
+	}
+	return info
+}
 
-	shortCode, err := generateShortCode()
+// newUpdateResult describes link after a change.
+func newUpdateResult(link Link) UpdateResult {
+	return UpdateResult{
+		URL:            link.URL,
+		ExpiresAt:      link.ExpiresAt,
+		RedirectStatus: link.RedirectStatus,
+		ETag:           link.ETag(),
+	}
+}
+
+// linkStatus reports whether link redirects at now. A link that is not
+// active yet counts as active, since it will redirect.
+func linkStatus(link Link, now time.Time) LinkStatus {
+	switch {
+	case link.Disabled:
+		return StatusDisabled
+	case !link.ExpiresAt.IsZero() && !now.Before(link.ExpiresAt),
+		!link.NotAfter.IsZero() && !now.Before(link.NotAfter),
+		link.MaxClicks > 0 && link.Clicks >= link.MaxClicks:
+		return StatusExpired
+	default:
+		return StatusActive
+	}
+}
+
+// UpdateLink repoints an existing link or changes its expiration or
+// redirect status. New values are validated as in ShortenURL.
+func (s *Service) UpdateLink(ctx context.Context, shortCode string, opts UpdateOptions) (UpdateResult, error) {
+	ctx, span := s.tracer.Start(ctx, "UpdateLink")
+	defer span.End()
+
+	var longURL string
+	if opts.URL != "" {
+		var err error
+		if longURL, err = parseURL(opts.URL); err != nil {
+			return UpdateResult{}, err
+		}
+	}
+
+	if opts.RedirectStatus != nil {
+		if err := validateRedirectStatus(*opts.RedirectStatus); err != nil {
+			return UpdateResult{}, err
+		}
+	}
+
+	now := s.now()
+	expiration := ShortenOptions{TTL: opts.TTL, ExpiresAt: opts.ExpiresAt, Permanent: opts.Permanent}
+	var expiresAt time.Time
+	if expiration.hasExpiration() {
+		ttl, err := s.ttl(expiration, now)
+		if err != nil {
+			return UpdateResult{}, err
+		}
+		if ttl > 0 {
+			expiresAt = now.Add(ttl)
+		}
+	}
+
+	link, err := s.store.Update(ctx, shortCode, now, func(current Link) (Link, error) {
+		if !etagMatches(opts.IfMatch, current.ETag()) {
+			return Link{}, ErrPreconditionFailed
+		}
+		if longURL != "" && longURL != current.URL {
+			current = s.repoint(current, longURL, opts.Actor, opts.Reason, now)
+		}
+		if expiration.hasExpiration() {
+			current.ExpiresAt = expiresAt
+		}
+		if opts.RedirectStatus != nil {
+			current.RedirectStatus = *opts.RedirectStatus
+		}
+		return current, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to generate short code: %w", err)
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "update", Err: err}
+		}
+		return UpdateResult{}, fmt.Errorf("failed to update link: %w", err)
 	}
 
-	err = s.store.Set(ctx, shortCode, longURL, 24*time.Hour)
+	return newUpdateResult(link), nil
+}
+
+// LinkHistory returns the destinations a link has been repointed away
+// from, oldest first, as far back as the history size allows. The
+// destinations of a password-protected link are left out.
+func (s *Service) LinkHistory(ctx context.Context, shortCode string) ([]HistoryEntry, error) {
+	ctx, span := s.tracer.Start(ctx, "LinkHistory")
+	defer span.End()
+
+	link, err := s.store.Get(ctx, shortCode)
+	if err != nil {
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "get", Err: err}
+		}
+		return nil, fmt.Errorf("failed to get link history: %w", err)
+	}
+	if link.PasswordHash != "" {
+		history := make([]HistoryEntry, len(link.History))
+		for i, entry := range link.History {
+			entry.URL = ""
+			history[i] = entry
+		}
+		return history, nil
+	}
+	return link.History, nil
+}
+
+// RollbackLink points a link back at the destination it had at an earlier
+// version. The rollback is itself recorded in the history, so it can be
+// undone the same way.
+func (s *Service) RollbackLink(ctx context.Context, shortCode string, opts RollbackOptions) (UpdateResult, error) {
+	ctx, span := s.tracer.Start(ctx, "RollbackLink")
+	defer span.End()
+
+	now := s.now()
+	link, err := s.store.Update(ctx, shortCode, now, func(current Link) (Link, error) {
+		if !etagMatches(opts.IfMatch, current.ETag()) {
+			return Link{}, ErrPreconditionFailed
+		}
+		entry, err := historyEntry(current.History, opts.Version)
+		if err != nil {
+			return Link{}, err
+		}
+		reason := opts.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("rollback to version %d", entry.Version)
+		}
+		return s.repoint(current, entry.URL, opts.Actor, reason, now), nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to store URL: %w", err)
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "update", Err: err}
+		}
+		return UpdateResult{}, fmt.Errorf("failed to roll back link: %w", err)
 	}
 
-	return shortCode, nil
+	return newUpdateResult(link), nil
+}
+
+// historyEntry finds the entry for version, or the latest one for nil.
+func historyEntry(history []HistoryEntry, version *int64) (HistoryEntry, error) {
+	if version == nil {
+		if len(history) == 0 {
+			return HistoryEntry{}, ErrNoHistory
+		}
+		return history[len(history)-1], nil
+	}
+	for _, entry := range history {
+		if entry.Version == *version {
+			return entry, nil
+		}
+	}
+	return HistoryEntry{}, UnknownVersionError{Version: *version}
 }
 
-func (s *Service) GetLongURL(ctx context.Context, shortCode string) (string, error) {
-	ctx, span := s.tracer.Start(ctx, "GetLongURL")
+// repoint changes link's destination to url and records the one it
+// replaces, dropping the oldest entries beyond the history size. The
+// history is copied, as link may be shared with the store.
+func (s *Service) repoint(link Link, url, actor, reason string, now time.Time) Link {
+	history := append(slices.Clip(link.History), HistoryEntry{
+		Version:   link.Version,
+		URL:       link.URL,
+		Actor:     actor,
+		ChangedAt: now,
+		Reason:    reason,
+	})
+	if len(history) > s.historySize {
+		history = history[len(history)-s.historySize:]
+	}
+	link.URL = url
+	link.History = history
+	return link
+}
+
+// RenewLink pushes a link's expiration out to opts.TTL from now. It never
+// shortens a link's lifetime, takes a sliding link no further than its
+// ceiling and leaves links that never expire alone.
+func (s *Service) RenewLink(ctx context.Context, shortCode string, opts RenewOptions) (UpdateResult, error) {
+	ctx, span := s.tracer.Start(ctx, "RenewLink")
 	defer span.End()
 
-	longURL, err := s.store.Get(ctx, shortCode)
+	switch {
+	case opts.TTL < 0:
+		return UpdateResult{}, InvalidExpirationError{Reason: "ttl must be positive"}
+	case s.maxTTL > 0 && opts.TTL > s.maxTTL:
+		return UpdateResult{}, InvalidExpirationError{Reason: fmt.Sprintf("links may live at most %s", s.maxTTL)}
+	}
+
+	ttl := opts.TTL
+	if ttl == 0 {
+		link, err := s.store.Get(ctx, shortCode)
+		if err != nil {
+			if ErrorKind(err) == KindInternal {
+				err = StorageError{Op: "get", Err: err}
+			}
+			return UpdateResult{}, fmt.Errorf("failed to renew link: %w", err)
+		}
+		ttl = link.Slide
+		if ttl == 0 {
+			ttl = s.defaultTTL
+		}
+	}
+
+	now := s.now()
+	link, err := s.store.Renew(ctx, shortCode, now, now.Add(ttl))
 	if err != nil {
-		return "", fmt.Errorf("failed to retrieve long URL: %w", err)
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "renew", Err: err}
+		}
+		return UpdateResult{}, fmt.Errorf("failed to renew link: %w", err)
+	}
+
+	return newUpdateResult(link), nil
+}
+
+// DeleteLink takes a link down for good. Its code keeps answering as gone
+// and is never reissued.
+func (s *Service) DeleteLink(ctx context.Context, shortCode string) error {
+	ctx, span := s.tracer.Start(ctx, "DeleteLink")
+	defer span.End()
+
+	if err := s.store.Delete(ctx, shortCode); err != nil {
+		if ErrorKind(err) == KindInternal {
+			err = StorageError{Op: "delete", Err: err}
+		}
+		return fmt.Errorf("failed to delete link: %w", err)
+	}
+	return nil
+}
+
+// etagMatches applies an If-Match header value to etag. Weak ETags never
+// match, as If-Match uses strong comparison.
+func etagMatches(ifMatch, etag string) bool {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return true
 	}
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
 
-	return longURL, nil
+// RedirectStatuses are the statuses a link may redirect with.
+var RedirectStatuses = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
+
+// validateRedirectStatus accepts one of the RedirectStatuses, or zero for
+// the server's default.
+func validateRedirectStatus(status int) error {
+	if status != 0 && !slices.Contains(RedirectStatuses, status) {
+		return InvalidRedirectError{Status: status}
+	}
+	return nil
 }
 
-func generateShortCode() (string, error) {
-	b := make([]byte, 6)
-	_, err := rand.Read(b)
+// parseURL validates a destination URL and returns its normalized form.
+func parseURL(longURL string) (string, error) {
+	parsedURL, err := url.Parse(longURL)
 	if err != nil {
-		return "", err
+		return "", InvalidURLError{Reason: err.Error()}
 	}
+	if parsedURL.Scheme == "" || parsedURL.Host == "" {
+		return "", InvalidURLError{Reason: "missing scheme or host"}
+	}
+	return normalizeURL(parsedURL), nil
+}
 
-	return base64.URLEncoding.EncodeToString(b)[:6], nil
+// normalizeURL returns the form of u that links are stored and deduplicated
+// under: the host is lowercased and a port that is the scheme's default is
+// dropped. url.Parse has already lowercased the scheme.
+func normalizeURL(u *url.URL) string {
+	n := *u
+	n.Host = strings.ToLower(n.Host)
+	switch port := n.Port(); {
+	case n.Scheme == "http" && port == "80", n.Scheme == "https" && port == "443":
+		n.Host = strings.TrimSuffix(n.Host, ":"+port)
+	}
+	return n.String()
 }
@@ -1,37 +1,1175 @@
-package shortener
+package shortener_test
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/enleur/shrink/internal/shortener"
 	"github.com/enleur/shrink/internal/storage"
-	"github.com/enleur/shrink/tests"
 	"github.com/stretchr/testify/assert"
 )
 
+type failingStore struct {
+	err error
+}
+
+func (s failingStore) Set(context.Context, string, shortener.Link) error {
+	return s.err
+}
+
+func (s failingStore) Create(context.Context, string, shortener.Link) error {
+	return s.err
+}
+
+func (s failingStore) Resolve(context.Context, string, time.Time) (shortener.Link, error) {
+	return shortener.Link{}, s.err
+}
+
+func (s failingStore) CreateBatch(context.Context, []shortener.LinkEntry) ([]error, error) {
+	return nil, s.err
+}
+
+func (s failingStore) Search(context.Context, shortener.LinkFilter, string, int) ([]shortener.LinkEntry, error) {
+	return nil, s.err
+}
+
+func (s failingStore) Scan(context.Context, string, int) ([]shortener.LinkEntry, string, error) {
+	return nil, "", s.err
+}
+
+func (s failingStore) Unlock(context.Context, string, time.Time) (shortener.Link, error) {
+	return shortener.Link{}, s.err
+}
+
+func (s failingStore) Get(context.Context, string) (shortener.Link, error) {
+	return shortener.Link{}, s.err
+}
+
+func (s failingStore) FindByURL(context.Context, string) (string, time.Time, error) {
+	return "", time.Time{}, s.err
+}
+
+func (s failingStore) FindByURLs(context.Context, []string) ([]shortener.URLMatch, error) {
+	return nil, s.err
+}
+
+func (s failingStore) Delete(context.Context, string) error {
+	return s.err
+}
+
+func (s failingStore) Renew(context.Context, string, time.Time, time.Time) (shortener.Link, error) {
+	return shortener.Link{}, s.err
+}
+
+func (s failingStore) Update(context.Context, string, time.Time, func(shortener.Link) (shortener.Link, error)) (shortener.Link, error) {
+	return shortener.Link{}, s.err
+}
+
 func TestShortenerService(t *testing.T) {
 	ctx := context.Background()
-	redisContainer, redisAddress := tests.SetupRedis(t)
 
-	defer func() {
-		if err := redisContainer.Terminate(ctx); err != nil {
-			t.Fatalf("failed to terminate container: %s", err)
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	service := shortener.NewService(store)
+
+	t.Run("Shorten and Retrieve URL", func(t *testing.T) {
+		longURL := "https://example.com"
+		link, err := service.ShortenURL(ctx, longURL, shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, link.Code)
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), link.ExpiresAt, 5*time.Second)
+
+		redirect, err := service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, longURL, redirect.URL)
+	})
+
+	t.Run("Retrieve Non-existent URL", func(t *testing.T) {
+		_, err := service.FollowLink(ctx, "nonexistent")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+		assert.Equal(t, shortener.KindNotFound, shortener.ErrorKind(err))
+	})
+
+	t.Run("Deduplicates Same URL", func(t *testing.T) {
+		first, err := service.ShortenURL(ctx, "https://example.com/dedupe", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+
+		second, err := service.ShortenURL(ctx, "https://EXAMPLE.com:443/dedupe", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, first.Code, second.Code)
+
+		forced, err := service.ShortenURL(ctx, "https://example.com/dedupe", shortener.ShortenOptions{ForceNew: true})
+		assert.NoError(t, err)
+		assert.NotEqual(t, first.Code, forced.Code)
+	})
+
+	t.Run("Deduplicates Only Longer Lived Links", func(t *testing.T) {
+		brief, err := service.ShortenURL(ctx, "https://example.com/lifetime", shortener.ShortenOptions{TTL: time.Hour})
+		assert.NoError(t, err)
+
+		permanent, err := service.ShortenURL(ctx, "https://example.com/lifetime", shortener.ShortenOptions{Permanent: true})
+		assert.NoError(t, err)
+		assert.NotEqual(t, brief.Code, permanent.Code)
+		assert.True(t, permanent.ExpiresAt.IsZero())
+
+		again, err := service.ShortenURL(ctx, "https://example.com/lifetime", shortener.ShortenOptions{TTL: time.Minute})
+		assert.NoError(t, err)
+		assert.Equal(t, permanent.Code, again.Code)
+	})
+
+	t.Run("Does Not Reuse Overwritten Link", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/moved", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		assert.NoError(t, store.Set(ctx, link.Code, shortener.Link{URL: "https://example.com/elsewhere", ExpiresAt: time.Now().Add(time.Hour)}))
+
+		again, err := service.ShortenURL(ctx, "https://example.com/moved", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		assert.NotEqual(t, link.Code, again.Code)
+	})
+
+	t.Run("Max Clicks", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/once", shortener.ShortenOptions{MaxClicks: 1})
+		assert.NoError(t, err)
+
+		again, err := service.ShortenURL(ctx, "https://example.com/once", shortener.ShortenOptions{MaxClicks: 1})
+		assert.NoError(t, err)
+		assert.NotEqual(t, link.Code, again.Code)
+
+		redirect, err := service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/once", redirect.URL)
+
+		_, err = service.FollowLink(ctx, link.Code)
+		assert.ErrorIs(t, err, shortener.ErrExhausted)
+		assert.Equal(t, shortener.KindExhausted, shortener.ErrorKind(err))
+
+		_, err = service.ShortenURL(ctx, "https://example.com", shortener.ShortenOptions{MaxClicks: -1})
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+	})
+
+	t.Run("Alias", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/launch", shortener.ShortenOptions{Alias: "launch2026"})
+		assert.NoError(t, err)
+		assert.Equal(t, "launch2026", link.Code)
+
+		redirect, err := service.FollowLink(ctx, "launch2026")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/launch", redirect.URL)
+	})
+
+	t.Run("Alias Taken", func(t *testing.T) {
+		_, err := service.ShortenURL(ctx, "https://example.com/other", shortener.ShortenOptions{Alias: "launch2026"})
+		var takenErr shortener.AliasTakenError
+		assert.ErrorAs(t, err, &takenErr)
+		assert.Equal(t, shortener.KindConflict, shortener.ErrorKind(err))
+
+		redirect, err := service.FollowLink(ctx, "launch2026")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/launch", redirect.URL)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/abuse", shortener.ShortenOptions{Alias: "takedown"})
+		assert.NoError(t, err)
+
+		assert.NoError(t, service.DeleteLink(ctx, link.Code))
+
+		_, err = service.FollowLink(ctx, link.Code)
+		assert.ErrorIs(t, err, shortener.ErrDisabled)
+		assert.Equal(t, shortener.KindDisabled, shortener.ErrorKind(err))
+
+		_, err = service.ShortenURL(ctx, "https://example.com/other", shortener.ShortenOptions{Alias: "takedown"})
+		var takenErr shortener.AliasTakenError
+		assert.ErrorAs(t, err, &takenErr)
+
+		again, err := service.ShortenURL(ctx, "https://example.com/abuse", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		assert.NotEqual(t, link.Code, again.Code)
+
+		err = service.DeleteLink(ctx, "missing")
+		assert.Equal(t, shortener.KindNotFound, shortener.ErrorKind(err))
+	})
+
+	t.Run("Invalid Alias", func(t *testing.T) {
+		for _, alias := range []string{"ab", "has space", "a/b", "Metrics", "shorten", strings.Repeat("a", 65)} {
+			_, err := service.ShortenURL(ctx, "https://example.com", shortener.ShortenOptions{Alias: alias})
+			assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err), alias)
 		}
-	}()
+	})
+}
+
+func TestShortenerServiceExpiration(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	t.Run("Requested Lifetimes", func(t *testing.T) {
+		service := shortener.NewService(store, shortener.WithDefaultTTL(time.Hour))
+		expiresAt := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+
+		tests := []struct {
+			name          string
+			opts          shortener.ShortenOptions
+			wantExpiresAt time.Time
+		}{
+			{name: "Default", opts: shortener.ShortenOptions{}, wantExpiresAt: time.Now().Add(time.Hour)},
+			{name: "TTL", opts: shortener.ShortenOptions{TTL: 2 * time.Hour}, wantExpiresAt: time.Now().Add(2 * time.Hour)},
+			{name: "Expires At", opts: shortener.ShortenOptions{ExpiresAt: expiresAt}, wantExpiresAt: expiresAt},
+			{name: "Permanent", opts: shortener.ShortenOptions{Permanent: true}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				tt.opts.ForceNew = true
+				link, err := service.ShortenURL(ctx, "https://example.com/"+tt.name, tt.opts)
+				assert.NoError(t, err)
+				assert.WithinDuration(t, tt.wantExpiresAt, link.ExpiresAt, 5*time.Second)
+
+				stored, err := store.Get(ctx, link.Code)
+				assert.NoError(t, err)
+				assert.WithinDuration(t, tt.wantExpiresAt, stored.ExpiresAt, 5*time.Second)
+			})
+		}
+	})
+
+	t.Run("Invalid Lifetimes", func(t *testing.T) {
+		service := shortener.NewService(store, shortener.WithMaxTTL(24*time.Hour))
+
+		for name, opts := range map[string]shortener.ShortenOptions{
+			"Negative TTL":       {TTL: -time.Hour},
+			"Past Expires At":    {ExpiresAt: time.Now().Add(-time.Hour)},
+			"Conflicting":        {TTL: time.Hour, Permanent: true},
+			"Above Max TTL":      {TTL: 48 * time.Hour},
+			"Permanent With Max": {Permanent: true},
+		} {
+			_, err := service.ShortenURL(ctx, "https://example.com", opts)
+			var expirationErr shortener.InvalidExpirationError
+			assert.ErrorAs(t, err, &expirationErr, name)
+			assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err), name)
+		}
+	})
+}
+
+func TestShortenerServiceActivationWindow(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	start := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	now := start.Add(-time.Minute)
+	service := shortener.NewService(store,
+		shortener.WithClock(func() time.Time { return now }),
+		shortener.WithDefaultTTL(0),
+	)
 
-	store, err := storage.NewRedisStore(redisAddress, 0)
+	t.Run("Window Boundaries", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/sale", shortener.ShortenOptions{NotBefore: start, NotAfter: end})
+		assert.NoError(t, err)
+
+		again, err := service.ShortenURL(ctx, "https://example.com/sale", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		assert.NotEqual(t, link.Code, again.Code)
+
+		tests := []struct {
+			name    string
+			at      time.Time
+			wantErr error
+		}{
+			{name: "Before", at: start.Add(-time.Nanosecond), wantErr: shortener.ErrNotYetActive},
+			{name: "At Start", at: start},
+			{name: "Before End", at: end.Add(-time.Nanosecond)},
+			{name: "At End", at: end, wantErr: shortener.ErrExpired},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				now = tt.at
+				redirect, err := service.FollowLink(ctx, link.Code)
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, "https://example.com/sale", redirect.URL)
+			})
+		}
+	})
+
+	t.Run("Invalid Windows", func(t *testing.T) {
+		now = start
+
+		for name, opts := range map[string]shortener.ShortenOptions{
+			"Reversed":     {NotBefore: end, NotAfter: start},
+			"Empty":        {NotBefore: start, NotAfter: start},
+			"Already Over": {NotAfter: start.Add(-time.Second)},
+		} {
+			_, err := service.ShortenURL(ctx, "https://example.com", opts)
+			var windowErr shortener.InvalidWindowError
+			assert.ErrorAs(t, err, &windowErr, name)
+			assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err), name)
+		}
+	})
+}
+
+func TestShortenerServiceUpdateLink(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	service := shortener.NewService(store)
+
+	t.Run("Repoint Keeps Remaining Lifetime", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/old", shortener.ShortenOptions{TTL: 2 * time.Hour})
+		assert.NoError(t, err)
+
+		updated, err := service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: "https://EXAMPLE.com:443/new"})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/new", updated.URL)
+		assert.True(t, link.ExpiresAt.Equal(updated.ExpiresAt))
+
+		redirect, err := service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/new", redirect.URL)
+	})
+
+	t.Run("New Lifetime", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/lifetime", shortener.ShortenOptions{ForceNew: true})
+		assert.NoError(t, err)
+
+		updated, err := service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{Permanent: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/lifetime", updated.URL)
+		assert.True(t, updated.ExpiresAt.IsZero())
+
+		updated, err = service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{TTL: time.Hour})
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), updated.ExpiresAt, 5*time.Second)
+	})
+
+	t.Run("If-Match", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/etag", shortener.ShortenOptions{ForceNew: true})
+		assert.NoError(t, err)
+
+		first, err := service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: "https://example.com/first"})
+		assert.NoError(t, err)
+
+		second, err := service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: "https://example.com/second", IfMatch: first.ETag})
+		assert.NoError(t, err)
+		assert.NotEqual(t, first.ETag, second.ETag)
+
+		_, err = service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: "https://example.com/stale", IfMatch: first.ETag})
+		assert.ErrorIs(t, err, shortener.ErrPreconditionFailed)
+		assert.Equal(t, shortener.KindPrecondition, shortener.ErrorKind(err))
+
+		_, err = service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: "https://example.com/listed", IfMatch: first.ETag + ", " + second.ETag})
+		assert.NoError(t, err)
+
+		redirect, err := service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/listed", redirect.URL)
+	})
+
+	t.Run("Invalid Updates", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/invalid", shortener.ShortenOptions{ForceNew: true})
+		assert.NoError(t, err)
+
+		for name, opts := range map[string]shortener.UpdateOptions{
+			"Relative URL": {URL: "/relative"},
+			"Negative TTL": {TTL: -time.Hour},
+			"Conflicting":  {TTL: time.Hour, Permanent: true},
+		} {
+			_, err := service.UpdateLink(ctx, link.Code, opts)
+			assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err), name)
+		}
+
+		_, err = service.UpdateLink(ctx, "missing", shortener.UpdateOptions{URL: "https://example.com"})
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+}
+func TestShortenerServiceHistory(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	service := shortener.NewService(store,
+		shortener.WithClock(func() time.Time { return now }),
+		shortener.WithHistorySize(3),
+	)
+
+	t.Run("Records Repoints", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/v0", shortener.ShortenOptions{ForceNew: true})
+		assert.NoError(t, err)
+
+		history, err := service.LinkHistory(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Empty(t, history)
+
+		_, err = service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: "https://example.com/v1", Actor: "alice", Reason: "typo"})
+		assert.NoError(t, err)
+		_, err = service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{Permanent: true})
+		assert.NoError(t, err)
+
+		history, err = service.LinkHistory(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, []shortener.HistoryEntry{
+			{Version: 0, URL: "https://example.com/v0", Actor: "alice", ChangedAt: now, Reason: "typo"},
+		}, history)
+	})
+
+	t.Run("Bounded Retention", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/r0", shortener.ShortenOptions{ForceNew: true})
+		assert.NoError(t, err)
+		for _, url := range []string{"https://example.com/r1", "https://example.com/r2", "https://example.com/r3", "https://example.com/r4"} {
+			_, err := service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: url})
+			assert.NoError(t, err)
+		}
+
+		history, err := service.LinkHistory(ctx, link.Code)
+		assert.NoError(t, err)
+		if assert.Len(t, history, 3) {
+			assert.Equal(t, "https://example.com/r1", history[0].URL)
+			assert.Equal(t, "https://example.com/r3", history[2].URL)
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/a", shortener.ShortenOptions{ForceNew: true})
+		assert.NoError(t, err)
+		_, err = service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: "https://example.com/b"})
+		assert.NoError(t, err)
+		updated, err := service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: "https://example.com/c"})
+		assert.NoError(t, err)
+
+		_, err = service.RollbackLink(ctx, link.Code, shortener.RollbackOptions{IfMatch: `"0"`})
+		assert.ErrorIs(t, err, shortener.ErrPreconditionFailed)
+
+		undone, err := service.RollbackLink(ctx, link.Code, shortener.RollbackOptions{IfMatch: updated.ETag, Actor: "bob"})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/b", undone.URL)
+
+		original := int64(0)
+		restored, err := service.RollbackLink(ctx, link.Code, shortener.RollbackOptions{Version: &original, Reason: "original"})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/a", restored.URL)
+
+		restored, err = service.RollbackLink(ctx, link.Code, shortener.RollbackOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/b", restored.URL)
+
+		history, err := service.LinkHistory(ctx, link.Code)
+		assert.NoError(t, err)
+		if assert.Len(t, history, 3) {
+			assert.Equal(t, "bob", history[0].Actor)
+			assert.Equal(t, "rollback to version 1", history[0].Reason)
+			assert.Equal(t, "original", history[1].Reason)
+			assert.Equal(t, "rollback to version 3", history[2].Reason)
+		}
+
+		redirect, err := service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/b", redirect.URL)
+	})
+
+	t.Run("Unknown Version", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/unchanged", shortener.ShortenOptions{ForceNew: true})
+		assert.NoError(t, err)
+
+		_, err = service.RollbackLink(ctx, link.Code, shortener.RollbackOptions{})
+		assert.ErrorIs(t, err, shortener.ErrNoHistory)
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+
+		_, err = service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: "https://example.com/changed"})
+		assert.NoError(t, err)
+		version := int64(5)
+		_, err = service.RollbackLink(ctx, link.Code, shortener.RollbackOptions{Version: &version})
+		assert.ErrorAs(t, err, new(shortener.UnknownVersionError))
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+	})
+
+	t.Run("Protected", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/secret-v0", shortener.ShortenOptions{Password: "hunter2"})
+		assert.NoError(t, err)
+		_, err = service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{URL: "https://example.com/secret-v1", Actor: "alice"})
+		assert.NoError(t, err)
+
+		history, err := service.LinkHistory(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, []shortener.HistoryEntry{
+			{Version: 0, Actor: "alice", ChangedAt: now},
+		}, history)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		_, err := service.LinkHistory(ctx, "missing")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+		_, err = service.RollbackLink(ctx, "missing", shortener.RollbackOptions{})
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+}
+func TestShortenerServiceSlidingExpiration(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	start := time.Now()
+	now := start
+	service := shortener.NewService(store,
+		shortener.WithClock(func() time.Time { return now }),
+		shortener.WithSlidingExpiration(time.Hour, 3*time.Hour),
+	)
+
+	t.Run("Redirects Renew Up To The Ceiling", func(t *testing.T) {
+		now = start
+		link, err := service.ShortenURL(ctx, "https://example.com/sliding", shortener.ShortenOptions{Sliding: true, TTL: 30 * time.Minute})
+		assert.NoError(t, err)
+		assert.True(t, start.Add(30*time.Minute).Equal(link.ExpiresAt))
+
+		now = start.Add(20 * time.Minute)
+		_, err = service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+		info, err := service.GetLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.True(t, now.Add(time.Hour).Equal(info.ExpiresAt))
+		assert.Equal(t, time.Hour, info.Slide)
+		assert.True(t, start.Add(3*time.Hour).Equal(info.MaxExpiresAt))
+
+		now = start.Add(70 * time.Minute)
+		_, err = service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+		now = start.Add(125 * time.Minute)
+		_, err = service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+		info, err = service.GetLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.True(t, start.Add(3*time.Hour).Equal(info.ExpiresAt))
+	})
+
+	t.Run("Not Deduplicated", func(t *testing.T) {
+		now = start
+		first, err := service.ShortenURL(ctx, "https://example.com/shared", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		second, err := service.ShortenURL(ctx, "https://example.com/shared", shortener.ShortenOptions{Sliding: true})
+		assert.NoError(t, err)
+		assert.NotEqual(t, first.Code, second.Code)
+		assert.True(t, start.Add(time.Hour).Equal(second.ExpiresAt))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := service.ShortenURL(ctx, "https://example.com/forever", shortener.ShortenOptions{Sliding: true, Permanent: true})
+		assert.ErrorAs(t, err, new(shortener.InvalidExpirationError))
+
+		disabled := shortener.NewService(store, shortener.WithSlidingExpiration(0, 0))
+		_, err = disabled.ShortenURL(ctx, "https://example.com/disabled", shortener.ShortenOptions{Sliding: true})
+		assert.ErrorAs(t, err, new(shortener.InvalidExpirationError))
+	})
+
+	t.Run("Renew", func(t *testing.T) {
+		now = start
+		fixed, err := service.ShortenURL(ctx, "https://example.com/fixed", shortener.ShortenOptions{ForceNew: true, TTL: time.Hour})
+		assert.NoError(t, err)
+
+		renewed, err := service.RenewLink(ctx, fixed.Code, shortener.RenewOptions{TTL: 5 * time.Hour})
+		assert.NoError(t, err)
+		assert.True(t, start.Add(5*time.Hour).Equal(renewed.ExpiresAt))
+
+		renewed, err = service.RenewLink(ctx, fixed.Code, shortener.RenewOptions{})
+		assert.NoError(t, err)
+		assert.True(t, start.Add(24*time.Hour).Equal(renewed.ExpiresAt))
+
+		sliding, err := service.ShortenURL(ctx, "https://example.com/renewed", shortener.ShortenOptions{Sliding: true, TTL: 30 * time.Minute})
+		assert.NoError(t, err)
+		now = start.Add(10 * time.Minute)
+		renewed, err = service.RenewLink(ctx, sliding.Code, shortener.RenewOptions{})
+		assert.NoError(t, err)
+		assert.True(t, now.Add(time.Hour).Equal(renewed.ExpiresAt))
+
+		_, err = service.RenewLink(ctx, fixed.Code, shortener.RenewOptions{TTL: -time.Hour})
+		assert.ErrorAs(t, err, new(shortener.InvalidExpirationError))
+		_, err = service.RenewLink(ctx, "missing", shortener.RenewOptions{})
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+}
+
+// collidingStore reports the first collisions calls to Create as taken.
+type collidingStore struct {
+	shortener.Store
+	collisions int
+	calls      int
+}
+
+func (s *collidingStore) Create(ctx context.Context, key string, link shortener.Link) error {
+	s.calls++
+	if s.calls <= s.collisions {
+		return shortener.ErrAlreadyExists
+	}
+	return s.Store.Create(ctx, key, link)
+}
+
+func (s *collidingStore) CreateBatch(ctx context.Context, entries []shortener.LinkEntry) ([]error, error) {
+	errs := make([]error, len(entries))
+	for i, entry := range entries {
+		errs[i] = s.Create(ctx, entry.Key, entry.Link)
+	}
+	return errs, nil
+}
+
+// lookupCountingStore records the calls to FindByURLs.
+type lookupCountingStore struct {
+	shortener.Store
+	calls int
+	urls  []string
+}
+
+func (s *lookupCountingStore) FindByURLs(ctx context.Context, urls []string) ([]shortener.URLMatch, error) {
+	s.calls++
+	s.urls = append(s.urls, urls...)
+	return s.Store.FindByURLs(ctx, urls)
+}
+
+func TestShortenerServicePassword(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	start := time.Now()
+	now := start
+	service := shortener.NewService(store,
+		shortener.WithClock(func() time.Time { return now }),
+		shortener.WithPasswordAttempts(2, time.Minute),
+	)
+
+	link, err := service.ShortenURL(ctx, "https://example.com/private", shortener.ShortenOptions{Password: "hunter2"})
 	assert.NoError(t, err)
 
-	service := NewService(store)
+	t.Run("Stored Hashed", func(t *testing.T) {
+		stored, err := store.Get(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.NotContains(t, stored.PasswordHash, "hunter2")
+		assert.True(t, shortener.VerifyPassword(stored.PasswordHash, "hunter2"))
+		assert.False(t, shortener.VerifyPassword(stored.PasswordHash, "hunter3"))
 
-	t.Run("Shorten and Retrieve URL", func(t *testing.T) {
-		longURL := "https://example.com"
-		shortCode, err := service.ShortenURL(ctx, longURL)
+		info, err := service.GetLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.True(t, info.Protected)
+	})
+
+	t.Run("Redirect Requires Password", func(t *testing.T) {
+		_, err := service.FollowLink(ctx, link.Code)
+		assert.ErrorIs(t, err, shortener.ErrPasswordRequired)
+		assert.Equal(t, shortener.KindPassword, shortener.ErrorKind(err))
+
+		redirect, err := service.UnlockLink(ctx, link.Code, "hunter2")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/private", redirect.URL)
+	})
+
+	t.Run("Locked After Incorrect Passwords", func(t *testing.T) {
+		now = start
+		for i := 0; i < 2; i++ {
+			_, err := service.UnlockLink(ctx, link.Code, "guess")
+			assert.ErrorIs(t, err, shortener.ErrIncorrectPassword)
+			assert.Equal(t, shortener.KindUnauthorized, shortener.ErrorKind(err))
+		}
+
+		now = start.Add(20 * time.Second)
+		_, err := service.UnlockLink(ctx, link.Code, "hunter2")
+		var throttled shortener.TooManyAttemptsError
+		assert.ErrorAs(t, err, &throttled)
+		assert.Equal(t, 40*time.Second, throttled.RetryAfter)
+		assert.Equal(t, shortener.KindThrottled, shortener.ErrorKind(err))
+
+		now = start.Add(time.Minute)
+		_, err = service.UnlockLink(ctx, link.Code, "hunter2")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Concurrent Guesses Throttled", func(t *testing.T) {
+		now = start.Add(time.Hour)
+		guessed, err := service.ShortenURL(ctx, "https://example.com/guessed", shortener.ShortenOptions{Password: "hunter2"})
+		assert.NoError(t, err)
+
+		errs := make([]error, 8)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = service.UnlockLink(ctx, guessed.Code, "guess")
+			}()
+		}
+		wg.Wait()
+
+		var incorrect, throttled int
+		for _, err := range errs {
+			switch shortener.ErrorKind(err) {
+			case shortener.KindUnauthorized:
+				incorrect++
+			case shortener.KindThrottled:
+				throttled++
+			}
+		}
+		assert.Equal(t, 2, incorrect)
+		assert.Equal(t, 6, throttled)
+	})
+
+	t.Run("Not Deduplicated", func(t *testing.T) {
+		open, err := service.ShortenURL(ctx, "https://example.com/private", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		assert.NotEqual(t, link.Code, open.Code)
+
+		redirect, err := service.UnlockLink(ctx, open.Code, "anything")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/private", redirect.URL)
+	})
+
+	t.Run("Too Long", func(t *testing.T) {
+		_, err := service.ShortenURL(ctx, "https://example.com/long", shortener.ShortenOptions{Password: strings.Repeat("x", 2000)})
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+	})
+}
+
+func TestShortenerServiceRedirectStatus(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	service := shortener.NewService(store)
+
+	plain, err := service.ShortenURL(ctx, "https://example.com/moved", shortener.ShortenOptions{})
+	assert.NoError(t, err)
+	link, err := service.ShortenURL(ctx, "https://example.com/moved", shortener.ShortenOptions{RedirectStatus: http.StatusMovedPermanently})
+	assert.NoError(t, err)
+
+	t.Run("Stored With The Link", func(t *testing.T) {
+		assert.NotEqual(t, plain.Code, link.Code)
+
+		redirect, err := service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, shortener.Redirect{URL: "https://example.com/moved", Status: http.StatusMovedPermanently}, redirect)
+
+		redirect, err = service.FollowLink(ctx, plain.Code)
+		assert.NoError(t, err)
+		assert.Zero(t, redirect.Status)
+
+		info, err := service.GetLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusMovedPermanently, info.RedirectStatus)
+	})
+
+	t.Run("Not Deduplicated", func(t *testing.T) {
+		again, err := service.ShortenURL(ctx, "https://example.com/moved", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, plain.Code, again.Code)
+
+		again, err = service.ShortenURL(ctx, "https://example.com/moved", shortener.ShortenOptions{RedirectStatus: http.StatusMovedPermanently})
+		assert.NoError(t, err)
+		assert.NotEqual(t, link.Code, again.Code)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		status := http.StatusPermanentRedirect
+		result, err := service.UpdateLink(ctx, plain.Code, shortener.UpdateOptions{RedirectStatus: &status})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusPermanentRedirect, result.RedirectStatus)
+
+		redirect, err := service.FollowLink(ctx, plain.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusPermanentRedirect, redirect.Status)
+
+		status = 0
+		result, err = service.UpdateLink(ctx, plain.Code, shortener.UpdateOptions{RedirectStatus: &status})
+		assert.NoError(t, err)
+		assert.Zero(t, result.RedirectStatus)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := service.ShortenURL(ctx, "https://example.com/ok", shortener.ShortenOptions{RedirectStatus: http.StatusOK})
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+
+		status := http.StatusSeeOther
+		_, err = service.UpdateLink(ctx, link.Code, shortener.UpdateOptions{RedirectStatus: &status})
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+	})
+}
+
+func TestShortenerServiceBatch(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	service := shortener.NewService(store, shortener.WithBatchLimit(5))
+
+	existing, err := service.ShortenURL(ctx, "https://example.com/existing", shortener.ShortenOptions{})
+	assert.NoError(t, err)
+
+	results, err := service.ShortenBatch(ctx, []shortener.BatchItem{
+		{URL: "https://example.com/new", Opts: shortener.ShortenOptions{TTL: time.Hour}},
+		{URL: "not a url"},
+		{URL: "https://example.com/aliased", Opts: shortener.ShortenOptions{Alias: "newsletter"}},
+		{URL: "https://example.com/again", Opts: shortener.ShortenOptions{Alias: "newsletter"}},
+		{URL: "https://example.com/existing"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 5)
+
+	assert.NoError(t, results[0].Err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), results[0].ExpiresAt, 5*time.Second)
+	redirect, err := service.FollowLink(ctx, results[0].Code)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/new", redirect.URL)
+
+	assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(results[1].Err))
+
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, "newsletter", results[2].Code)
+	var taken shortener.AliasTakenError
+	assert.ErrorAs(t, results[3].Err, &taken)
+	redirect, err = service.FollowLink(ctx, "newsletter")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/aliased", redirect.URL)
+
+	assert.NoError(t, results[4].Err)
+	assert.Equal(t, existing.Code, results[4].Code)
+
+	t.Run("Looks URLs Up Together", func(t *testing.T) {
+		lookups := &lookupCountingStore{Store: store}
+		service := shortener.NewService(lookups)
+
+		results, err := service.ShortenBatch(ctx, []shortener.BatchItem{
+			{URL: "https://example.com/existing"},
+			{URL: "https://example.com/fresh"},
+			{URL: "https://example.com/existing", Opts: shortener.ShortenOptions{ForceNew: true}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, existing.Code, results[0].Code)
+		assert.NotEqual(t, existing.Code, results[1].Code)
+		assert.NotEqual(t, existing.Code, results[2].Code)
+		assert.Equal(t, 1, lookups.calls)
+		assert.Equal(t, []string{"https://example.com/existing", "https://example.com/fresh"}, lookups.urls)
+	})
+
+	t.Run("Lookup Failure", func(t *testing.T) {
+		service := shortener.NewService(failingStore{err: errors.New("connection refused")})
+
+		results, err := service.ShortenBatch(ctx, []shortener.BatchItem{{URL: "https://example.com/existing"}})
 		assert.NoError(t, err)
-		assert.NotEmpty(t, shortCode)
+		assert.Equal(t, shortener.KindUnavailable, shortener.ErrorKind(results[0].Err))
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		_, err := service.ShortenBatch(ctx, make([]shortener.BatchItem, 6))
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+	})
+}
+
+func TestShortenerServiceGetLink(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	now := time.Now().Truncate(time.Millisecond)
+	service := shortener.NewService(store, shortener.WithClock(func() time.Time { return now }))
+
+	t.Run("Active", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/inspected", shortener.ShortenOptions{TTL: time.Hour, Creator: "alice"})
+		assert.NoError(t, err)
+		_, err = service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+
+		info, err := service.GetLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, link.Code, info.Code)
+		assert.Equal(t, "https://example.com/inspected", info.URL)
+		assert.True(t, now.Equal(info.CreatedAt))
+		assert.Equal(t, "alice", info.Creator)
+		assert.Equal(t, int64(1), info.Clicks)
+		assert.Equal(t, time.Hour, info.TTL)
+		assert.Equal(t, shortener.StatusActive, info.Status)
+		assert.NotEmpty(t, info.ETag)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/once", shortener.ShortenOptions{MaxClicks: 1})
+		assert.NoError(t, err)
+		_, err = service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+
+		info, err := service.GetLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, shortener.StatusExpired, info.Status)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		link, err := service.ShortenURL(ctx, "https://example.com/removed", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		assert.NoError(t, service.DeleteLink(ctx, link.Code))
+
+		info, err := service.GetLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, shortener.StatusDisabled, info.Status)
+		assert.Zero(t, info.TTL)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := service.GetLink(ctx, "missing")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+}
+
+func TestShortenerServiceListLinks(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	now := time.Now().Truncate(time.Millisecond)
+	service := shortener.NewService(store, shortener.WithClock(func() time.Time { return now }))
+
+	for _, alias := range []string{"list-a", "list-b", "list-c"} {
+		_, err := service.ShortenURL(ctx, "https://Docs.Example.com/"+alias, shortener.ShortenOptions{
+			Alias: alias, Title: "Guide " + alias, Tags: []string{"Docs", "docs", "team-a"}, Creator: "alice",
+		})
+		assert.NoError(t, err)
+	}
+	_, err := service.ShortenURL(ctx, "https://example.org/other", shortener.ShortenOptions{Alias: "list-d", Creator: "bob"})
+	assert.NoError(t, err)
+	_, err = service.ShortenURL(ctx, "https://example.org/once", shortener.ShortenOptions{Alias: "list-e", Creator: "bob", MaxClicks: 1})
+	assert.NoError(t, err)
+	_, err = service.FollowLink(ctx, "list-e")
+	assert.NoError(t, err)
+
+	t.Run("Metadata", func(t *testing.T) {
+		info, err := service.GetLink(ctx, "list-a")
+		assert.NoError(t, err)
+		assert.Equal(t, "Guide list-a", info.Title)
+		assert.Equal(t, []string{"docs", "team-a"}, info.Tags)
+	})
+
+	t.Run("Labeled Links Are Not Shared", func(t *testing.T) {
+		plain, err := service.ShortenURL(ctx, "https://example.com/shared", shortener.ShortenOptions{})
+		assert.NoError(t, err)
+		tagged, err := service.ShortenURL(ctx, "https://example.com/shared", shortener.ShortenOptions{Tags: []string{"mine"}})
+		assert.NoError(t, err)
+		assert.NotEqual(t, plain.Code, tagged.Code)
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		page, err := service.ListLinks(ctx, shortener.LinkFilter{Tag: " DOCS "}, "", 2)
+		assert.NoError(t, err)
+		if assert.Len(t, page.Links, 2) {
+			assert.Equal(t, "list-a", page.Links[0].Code)
+			assert.Equal(t, "list-b", page.Links[1].Code)
+		}
+		assert.NotEmpty(t, page.Cursor)
+
+		page, err = service.ListLinks(ctx, shortener.LinkFilter{Tag: "docs"}, page.Cursor, 2)
+		assert.NoError(t, err)
+		if assert.Len(t, page.Links, 1) {
+			assert.Equal(t, "list-c", page.Links[0].Code)
+			assert.Equal(t, shortener.StatusActive, page.Links[0].Status)
+		}
+		assert.Empty(t, page.Cursor)
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		page, err := service.ListLinks(ctx, shortener.LinkFilter{Domain: "DOCS.example.com", Text: "GUIDE LIST-B"}, "", 0)
+		assert.NoError(t, err)
+		if assert.Len(t, page.Links, 1) {
+			assert.Equal(t, "list-b", page.Links[0].Code)
+		}
+
+		page, err = service.ListLinks(ctx, shortener.LinkFilter{Creator: "bob", CreatedFrom: now, CreatedTo: now.Add(time.Second)}, "", 0)
+		assert.NoError(t, err)
+		if assert.Len(t, page.Links, 1) {
+			assert.Equal(t, "list-d", page.Links[0].Code)
+		}
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		var codes []string
+		cursor := ""
+		for {
+			page, err := service.ScanLinks(ctx, cursor, 2)
+			assert.NoError(t, err)
+			for _, info := range page.Links {
+				codes = append(codes, info.Code)
+			}
+			if page.Cursor == "" || err != nil {
+				break
+			}
+			cursor = page.Cursor
+		}
+		assert.Subset(t, codes, []string{"list-a", "list-b", "list-c", "list-d", "list-e"})
+
+		_, err := service.ScanLinks(ctx, "not a cursor!", 0)
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := service.ListLinks(ctx, shortener.LinkFilter{}, "not a cursor!", 0)
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+		_, err = service.ListLinks(ctx, shortener.LinkFilter{}, "", -1)
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+		_, err = service.ListLinks(ctx, shortener.LinkFilter{CreatedFrom: now, CreatedTo: now}, "", 0)
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+
+		_, err = service.ShortenURL(ctx, "https://example.com/bad-tag", shortener.ShortenOptions{Tags: []string{"no spaces"}})
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+		_, err = service.ShortenURL(ctx, "https://example.com/long-title", shortener.ShortenOptions{Title: strings.Repeat("x", 201)})
+		assert.Equal(t, shortener.KindInvalid, shortener.ErrorKind(err))
+	})
+}
+
+func TestShortenerServiceCollisions(t *testing.T) {
+	ctx := context.Background()
+
+	memory := storage.NewMemoryStore(0)
+	defer func() { _ = memory.Close() }()
+
+	t.Run("Retries With Fresh Code", func(t *testing.T) {
+		store := &collidingStore{Store: memory, collisions: 2}
+		service := shortener.NewService(store, shortener.WithMaxAttempts(3))
+
+		link, err := service.ShortenURL(ctx, "https://example.com", shortener.ShortenOptions{ForceNew: true})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, store.calls)
+
+		redirect, err := service.FollowLink(ctx, link.Code)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com", redirect.URL)
+	})
+
+	t.Run("Gives Up After Max Attempts", func(t *testing.T) {
+		store := &collidingStore{Store: memory, collisions: 3}
+		service := shortener.NewService(store, shortener.WithMaxAttempts(3))
+
+		_, err := service.ShortenURL(ctx, "https://example.com", shortener.ShortenOptions{ForceNew: true})
+		assert.ErrorIs(t, err, shortener.ErrCodeSpaceExhausted)
+		assert.NotErrorIs(t, err, shortener.ErrAlreadyExists)
+		assert.Equal(t, shortener.KindInternal, shortener.ErrorKind(err))
+		assert.Equal(t, 3, store.calls)
+	})
+
+	t.Run("Batch Gives Up After Max Attempts", func(t *testing.T) {
+		store := &collidingStore{Store: memory, collisions: 2}
+		service := shortener.NewService(store, shortener.WithMaxAttempts(2))
+
+		results, err := service.ShortenBatch(ctx, []shortener.BatchItem{
+			{URL: "https://example.com/unlucky", Opts: shortener.ShortenOptions{ForceNew: true}},
+		})
+		assert.NoError(t, err)
+		assert.ErrorIs(t, results[0].Err, shortener.ErrCodeSpaceExhausted)
+		assert.Equal(t, shortener.KindInternal, shortener.ErrorKind(results[0].Err))
+	})
+
+	t.Run("Batch Retries Colliding Items", func(t *testing.T) {
+		store := &collidingStore{Store: memory, collisions: 1}
+		service := shortener.NewService(store, shortener.WithMaxAttempts(2))
+
+		results, err := service.ShortenBatch(ctx, []shortener.BatchItem{
+			{URL: "https://example.com/first", Opts: shortener.ShortenOptions{ForceNew: true}},
+			{URL: "https://example.com/second", Opts: shortener.ShortenOptions{ForceNew: true}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, store.calls)
+		for _, result := range results {
+			assert.NoError(t, result.Err)
+			assert.NotEmpty(t, result.Code)
+		}
+	})
+}
+
+// sequenceGenerator hands out codes in order.
+type sequenceGenerator struct {
+	codes []string
+}
+
+func (g *sequenceGenerator) Generate(context.Context) (string, error) {
+	code := g.codes[0]
+	g.codes = g.codes[1:]
+	return code, nil
+}
+
+func TestShortenerServiceReservedCodes(t *testing.T) {
+	ctx := context.Background()
+
+	store := storage.NewMemoryStore(0)
+	defer func() { _ = store.Close() }()
+
+	t.Run("Skipped When Generated", func(t *testing.T) {
+		service := shortener.NewService(store, shortener.WithMaxAttempts(3),
+			shortener.WithCodeGenerator(&sequenceGenerator{codes: []string{"links", "Metrics", "abc123"}}))
+
+		link, err := service.ShortenURL(ctx, "https://example.com/reserved", shortener.ShortenOptions{ForceNew: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "abc123", link.Code)
 
-		retrievedURL, err := service.GetLongURL(ctx, shortCode)
+		_, err = store.Get(ctx, "links")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("Gives Up After Max Attempts", func(t *testing.T) {
+		service := shortener.NewService(store, shortener.WithMaxAttempts(2),
+			shortener.WithCodeGenerator(&sequenceGenerator{codes: []string{"shorten", "healthz"}}))
+
+		_, err := service.ShortenURL(ctx, "https://example.com/reserved", shortener.ShortenOptions{ForceNew: true})
+		assert.ErrorIs(t, err, shortener.ErrCodeSpaceExhausted)
+	})
+
+	t.Run("Skipped In Batches", func(t *testing.T) {
+		service := shortener.NewService(store, shortener.WithMaxAttempts(2),
+			shortener.WithCodeGenerator(&sequenceGenerator{codes: []string{"def456", "links", "ghi789"}}))
+
+		results, err := service.ShortenBatch(ctx, []shortener.BatchItem{
+			{URL: "https://example.com/first", Opts: shortener.ShortenOptions{ForceNew: true}},
+			{URL: "https://example.com/second", Opts: shortener.ShortenOptions{ForceNew: true}},
+		})
 		assert.NoError(t, err)
-		assert.Equal(t, longURL, retrievedURL)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "def456", results[0].Code)
+		assert.NoError(t, results[1].Err)
+		assert.Equal(t, "ghi789", results[1].Code)
+	})
+}
+
+func TestShortenerServiceStoreFailure(t *testing.T) {
+	ctx := context.Background()
+	service := shortener.NewService(failingStore{err: errors.New("connection refused")})
+
+	_, err := service.FollowLink(ctx, "abc123")
+	assert.Equal(t, shortener.KindUnavailable, shortener.ErrorKind(err))
+	assert.NotErrorIs(t, err, shortener.ErrNotFound)
+
+	_, err = service.ShortenURL(ctx, "https://example.com", shortener.ShortenOptions{})
+	assert.Equal(t, shortener.KindUnavailable, shortener.ErrorKind(err))
+
+	results, err := service.ShortenBatch(ctx, []shortener.BatchItem{
+		{URL: "https://example.com"},
+		{URL: "https://example.com", Opts: shortener.ShortenOptions{Alias: "mine"}},
 	})
+	assert.NoError(t, err)
+	for _, result := range results {
+		assert.Equal(t, shortener.KindUnavailable, shortener.ErrorKind(result.Err))
+	}
+	_, err = service.ListLinks(ctx, shortener.LinkFilter{}, "", 0)
+	assert.Equal(t, shortener.KindUnavailable, shortener.ErrorKind(err))
+	_, err = service.ScanLinks(ctx, "", 0)
+	assert.Equal(t, shortener.KindUnavailable, shortener.ErrorKind(err))
 }
@@ -0,0 +1,612 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/enleur/shrink/internal/shortener"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	linksBucket    = []byte("links")
+	expiryBucket   = []byte("expiry")
+	countersBucket = []byte("counters")
+	urlsBucket     = []byte("urls")
+)
+
+// BoltStore keeps links in an embedded bbolt database file, for single-node
+// deployments that don't want to run Redis.
+//
+// Links are stored in the links bucket as versioned JSON records. Every
+// redirect is counted in a write transaction. Links with an
+// expiration also get an entry in the expiry bucket, keyed by expiration
+// time, so the periodic sweep only visits keys that are due. The urls bucket
+// maps each URL to the key last written with it.
+type BoltStore struct {
+	db *bolt.DB
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// boltRecordVersion is the schema version written into new records.
+// Records without one predate versioning and decode the same way.
+const boltRecordVersion = 1
+
+type boltRecord struct {
+	V            int             `json:"v,omitempty"`
+	URL          string          `json:"url"`
+	ExpiresAt    time.Time       `json:"expiresAt,omitempty"`
+	Slide        time.Duration   `json:"slide,omitempty"`
+	MaxExpiresAt time.Time       `json:"maxExpiresAt,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt,omitempty"`
+	Creator      string          `json:"creator,omitempty"`
+	Title        string          `json:"title,omitempty"`
+	Description  string          `json:"description,omitempty"`
+	Tags         []string        `json:"tags,omitempty"`
+	MaxClicks    int64           `json:"maxClicks,omitempty"`
+	Clicks       int64           `json:"clicks,omitempty"`
+	NotBefore    time.Time       `json:"notBefore,omitempty"`
+	NotAfter     time.Time       `json:"notAfter,omitempty"`
+	Redirect     int             `json:"redirect,omitempty"`
+	Version      int64           `json:"version,omitempty"`
+	Disabled     bool            `json:"disabled,omitempty"`
+	PasswordHash string          `json:"passwordHash,omitempty"`
+	History      []historyRecord `json:"history,omitempty"`
+}
+
+func newBoltRecord(link shortener.Link) boltRecord {
+	return boltRecord{
+		V:            boltRecordVersion,
+		URL:          link.URL,
+		ExpiresAt:    utc(link.ExpiresAt),
+		Slide:        link.Slide,
+		MaxExpiresAt: utc(link.MaxExpiresAt),
+		CreatedAt:    utc(link.CreatedAt),
+		Creator:      link.Creator,
+		Title:        link.Title,
+		Description:  link.Description,
+		Tags:         link.Tags,
+		MaxClicks:    link.MaxClicks,
+		Clicks:       link.Clicks,
+		NotBefore:    utc(link.NotBefore),
+		NotAfter:     utc(link.NotAfter),
+		Redirect:     link.RedirectStatus,
+		Version:      link.Version,
+		Disabled:     link.Disabled,
+		PasswordHash: link.PasswordHash,
+		History:      newHistoryRecords(link.History),
+	}
+}
+
+func (r boltRecord) link() shortener.Link {
+	return shortener.Link{
+		URL:            r.URL,
+		ExpiresAt:      r.ExpiresAt,
+		Slide:          r.Slide,
+		MaxExpiresAt:   r.MaxExpiresAt,
+		CreatedAt:      r.CreatedAt,
+		Creator:        r.Creator,
+		Title:          r.Title,
+		Description:    r.Description,
+		Tags:           r.Tags,
+		MaxClicks:      r.MaxClicks,
+		Clicks:         r.Clicks,
+		NotBefore:      r.NotBefore,
+		NotAfter:       r.NotAfter,
+		Version:        r.Version,
+		Disabled:       r.Disabled,
+		PasswordHash:   r.PasswordHash,
+		History:        historyEntries(r.History),
+		RedirectStatus: r.Redirect,
+	}
+}
+
+// NewBoltStore opens (or creates) the database in dataDir. Expired links are
+// removed every sweepInterval; a non-positive interval disables the sweep.
+func NewBoltStore(dataDir string, sweepInterval time.Duration) (*BoltStore, error) {
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	db, err := bolt.Open(filepath.Join(dataDir, "links.db"), 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{linksBucket, expiryBucket, countersBucket, urlsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+
+	s := &BoltStore{
+		db:   db,
+		done: make(chan struct{}),
+	}
+
+	if sweepInterval > 0 {
+		s.wg.Add(1)
+		go s.sweep(sweepInterval)
+	}
+
+	return s, nil
+}
+
+func (s *BoltStore) Set(_ context.Context, key string, link shortener.Link) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putRecord(tx, key, newBoltRecord(link))
+	})
+}
+
+func (s *BoltStore) Create(_ context.Context, key string, link shortener.Link) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return createRecord(tx, key, link)
+	})
+}
+
+// CreateBatch creates every entry in one write transaction. Entries whose
+// key is taken are skipped; any other failure rolls the whole batch back.
+func (s *BoltStore) CreateBatch(_ context.Context, entries []shortener.LinkEntry) ([]error, error) {
+	errs := make([]error, len(entries))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for i, entry := range entries {
+			err := createRecord(tx, entry.Key, entry.Link)
+			if err != nil && !errors.Is(err, shortener.ErrAlreadyExists) {
+				return err
+			}
+			errs[i] = err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// Resolve counts the click in a write transaction, which bbolt serializes,
+// so clicks can't race with each other.
+func (s *BoltStore) Resolve(_ context.Context, key string, now time.Time) (shortener.Link, error) {
+	return s.resolve(key, now, false)
+}
+
+func (s *BoltStore) Unlock(_ context.Context, key string, now time.Time) (shortener.Link, error) {
+	return s.resolve(key, now, true)
+}
+
+// resolve follows the link at key; password-protected links are only
+// followed when unlock is set.
+func (s *BoltStore) resolve(key string, now time.Time, unlock bool) (shortener.Link, error) {
+	var record boltRecord
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		r, ok, err := getRecord(tx, key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return shortener.ErrNotFound
+		}
+		if expired(r.link(), now) {
+			return shortener.ErrExpired
+		}
+		if r.Disabled {
+			return shortener.ErrDisabled
+		}
+		if err := r.link().Active(now); err != nil {
+			return err
+		}
+		if r.MaxClicks > 0 && r.Clicks >= r.MaxClicks {
+			return shortener.ErrExhausted
+		}
+		if r.PasswordHash != "" && !unlock {
+			return shortener.ErrPasswordRequired
+		}
+		r.Clicks++
+		if r.Slide > 0 {
+			r.ExpiresAt = utc(r.link().Renewal(now.Add(r.Slide)))
+		}
+		record = r
+		return putRecord(tx, key, r)
+	})
+	if err != nil {
+		return shortener.Link{}, err
+	}
+
+	return record.link(), nil
+}
+
+func (s *BoltStore) Update(_ context.Context, key string, now time.Time, fn func(shortener.Link) (shortener.Link, error)) (shortener.Link, error) {
+	var record boltRecord
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		r, ok, err := getRecord(tx, key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return shortener.ErrNotFound
+		}
+		if expired(r.link(), now) {
+			return shortener.ErrExpired
+		}
+		if r.Disabled {
+			return shortener.ErrDisabled
+		}
+
+		link, err := fn(r.link())
+		if err != nil {
+			return err
+		}
+		r.V = boltRecordVersion
+		r.URL = link.URL
+		r.ExpiresAt = utc(link.ExpiresAt)
+		r.Redirect = link.RedirectStatus
+		r.History = newHistoryRecords(link.History)
+		r.Version++
+		record = r
+		return putRecord(tx, key, r)
+	})
+	if err != nil {
+		return shortener.Link{}, err
+	}
+	return record.link(), nil
+}
+
+func (s *BoltStore) Renew(_ context.Context, key string, now, expiresAt time.Time) (shortener.Link, error) {
+	var record boltRecord
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		r, ok, err := getRecord(tx, key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return shortener.ErrNotFound
+		}
+		if expired(r.link(), now) {
+			return shortener.ErrExpired
+		}
+		if r.Disabled {
+			return shortener.ErrDisabled
+		}
+		r.V = boltRecordVersion
+		r.ExpiresAt = utc(r.link().Renewal(expiresAt))
+		record = r
+		return putRecord(tx, key, r)
+	})
+	if err != nil {
+		return shortener.Link{}, err
+	}
+	return record.link(), nil
+}
+
+func (s *BoltStore) Delete(_ context.Context, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		record, ok, err := getRecord(tx, key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return shortener.ErrNotFound
+		}
+		if record.Disabled {
+			return nil
+		}
+		record.V = boltRecordVersion
+		record.Disabled = true
+		record.ExpiresAt = time.Time{}
+		record.Version++
+		return putRecord(tx, key, record)
+	})
+}
+
+// Next returns the next value of the counters bucket sequence, which bbolt
+// persists with the database.
+func (s *BoltStore) Next(context.Context) (uint64, error) {
+	var n uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.Bucket(countersBucket).NextSequence()
+		return err
+	})
+	return n, err
+}
+
+func (s *BoltStore) Get(_ context.Context, key string) (shortener.Link, error) {
+	var record boltRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		r, ok, err := getRecord(tx, key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return shortener.ErrNotFound
+		}
+		record = r
+		return nil
+	})
+	if err != nil {
+		return shortener.Link{}, err
+	}
+
+	return record.link(), nil
+}
+
+func (s *BoltStore) FindByURL(_ context.Context, url string) (string, time.Time, error) {
+	var key string
+	var expiresAt time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		key, expiresAt, err = findByURL(tx, url, time.Now())
+		return err
+	})
+	return key, expiresAt, err
+}
+
+// FindByURLs looks every URL up in one read transaction.
+func (s *BoltStore) FindByURLs(_ context.Context, urls []string) ([]shortener.URLMatch, error) {
+	now := time.Now()
+	matches := make([]shortener.URLMatch, len(urls))
+	err := s.db.View(func(tx *bolt.Tx) error {
+		for i, url := range urls {
+			matches[i].Key, matches[i].ExpiresAt, matches[i].Err = findByURL(tx, url, now)
+			if matches[i].Err != nil && !errors.Is(matches[i].Err, shortener.ErrNotFound) {
+				return matches[i].Err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+func findByURL(tx *bolt.Tx, url string, now time.Time) (string, time.Time, error) {
+	k := tx.Bucket(urlsBucket).Get([]byte(url))
+	if k == nil {
+		return "", time.Time{}, shortener.ErrNotFound
+	}
+	record, ok, err := getRecord(tx, string(k))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !ok || record.URL != url || !record.link().Shareable() || record.Disabled || expired(record.link(), now) {
+		return "", time.Time{}, shortener.ErrNotFound
+	}
+	return string(k), record.ExpiresAt, nil
+}
+
+func (s *BoltStore) Iterate(ctx context.Context, fn func(key string, link shortener.Link) error) error {
+	now := time.Now()
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(linksBucket).ForEach(func(k, v []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			record, err := decodeRecord(k, v)
+			if err != nil {
+				return err
+			}
+			link := record.link()
+			if expired(link, now) {
+				return nil
+			}
+			return fn(string(k), link)
+		})
+	})
+}
+
+// Search walks the links bucket in key order from after, filtering each
+// record; bbolt keeps no secondary indexes for the filter.
+func (s *BoltStore) Search(ctx context.Context, filter shortener.LinkFilter, after string, limit int) ([]shortener.LinkEntry, error) {
+	now := time.Now()
+	var entries []shortener.LinkEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(linksBucket).Cursor()
+		k, v := c.Seek([]byte(after))
+		if k != nil && string(k) == after {
+			k, v = c.Next()
+		}
+		for ; k != nil && len(entries) < limit; k, v = c.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			record, err := decodeRecord(k, v)
+			if err != nil {
+				return err
+			}
+			link := record.link()
+			if expired(link, now) || link.Disabled || !filter.Matches(link) {
+				continue
+			}
+			entries = append(entries, shortener.LinkEntry{Key: string(k), Link: link})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// Scan lists links in key order, the cursor being the last key listed.
+func (s *BoltStore) Scan(ctx context.Context, cursor string, limit int) ([]shortener.LinkEntry, string, error) {
+	entries, err := s.Search(ctx, shortener.LinkFilter{}, cursor, limit)
+	return entries, scanCursor(entries, limit), err
+}
+
+func (s *BoltStore) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.wg.Wait()
+	return s.db.Close()
+}
+
+func (s *BoltStore) sweep(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			_ = s.deleteExpired(time.Now())
+		}
+	}
+}
+
+// deleteExpired removes every link whose expiration is at or before now.
+func (s *BoltStore) deleteExpired(now time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		links := tx.Bucket(linksBucket)
+		expiry := tx.Bucket(expiryBucket)
+
+		// Collect first: deleting under a live cursor can skip keys.
+		var due [][]byte
+		limit := expiryKey(now, "")
+		c := expiry.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], limit) <= 0; k, _ = c.Next() {
+			due = append(due, bytes.Clone(k))
+		}
+
+		for _, k := range due {
+			key := string(k[8:])
+			record, ok, err := getRecord(tx, key)
+			if err != nil {
+				return err
+			}
+			if ok {
+				if err := unindexURL(tx, key, record); err != nil {
+					return err
+				}
+			}
+			if err := links.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := expiry.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// createRecord stores link at key unless it holds a live link.
+func createRecord(tx *bolt.Tx, key string, link shortener.Link) error {
+	current, ok, err := getRecord(tx, key)
+	if err != nil {
+		return err
+	}
+	if ok && !expired(current.link(), time.Now()) {
+		return shortener.ErrAlreadyExists
+	}
+	link.Clicks, link.Version, link.Disabled, link.History = 0, 0, false, nil
+	return putRecord(tx, key, newBoltRecord(link))
+}
+
+func getRecord(tx *bolt.Tx, key string) (boltRecord, bool, error) {
+	data := tx.Bucket(linksBucket).Get([]byte(key))
+	if data == nil {
+		return boltRecord{}, false, nil
+	}
+	record, err := decodeRecord([]byte(key), data)
+	if err != nil {
+		return boltRecord{}, false, err
+	}
+	return record, true, nil
+}
+
+// decodeRecord refuses records written by a newer schema, which could hold
+// fields this version would silently drop on the next write.
+func decodeRecord(key, data []byte) (boltRecord, error) {
+	var record boltRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return boltRecord{}, fmt.Errorf("failed to decode record %q: %w", key, err)
+	}
+	if record.V > boltRecordVersion {
+		return boltRecord{}, fmt.Errorf("record %q has unsupported schema version %d", key, record.V)
+	}
+	return record, nil
+}
+
+// putRecord writes record and keeps the expiry and URL indexes in sync with
+// it.
+func putRecord(tx *bolt.Tx, key string, record boltRecord) error {
+	current, ok, err := getRecord(tx, key)
+	if err != nil {
+		return err
+	}
+
+	expiry := tx.Bucket(expiryBucket)
+	if ok && !current.ExpiresAt.IsZero() {
+		if err := expiry.Delete(expiryKey(current.ExpiresAt, key)); err != nil {
+			return err
+		}
+	}
+	if !record.ExpiresAt.IsZero() {
+		if err := expiry.Put(expiryKey(record.ExpiresAt, key), nil); err != nil {
+			return err
+		}
+	}
+
+	if ok {
+		if err := unindexURL(tx, key, current); err != nil {
+			return err
+		}
+	}
+	if record.link().Shareable() && !record.Disabled && record.URL != "" && len(record.URL) <= bolt.MaxKeySize {
+		if err := tx.Bucket(urlsBucket).Put([]byte(record.URL), []byte(key)); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(linksBucket).Put([]byte(key), data)
+}
+
+// unindexURL drops the URL index entry for record, unless a later write of
+// the same URL to another key has taken it over.
+func unindexURL(tx *bolt.Tx, key string, record boltRecord) error {
+	urls := tx.Bucket(urlsBucket)
+	if record.URL == "" || string(urls.Get([]byte(record.URL))) != key {
+		return nil
+	}
+	return urls.Delete([]byte(record.URL))
+}
+
+// expiryKey sorts by expiration first: 8 bytes of big-endian unix nanos
+// followed by the link key.
+func expiryKey(t time.Time, key string) []byte {
+	k := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(t.UnixNano()))
+	copy(k[8:], key)
+	return k
+}
+
+func utc(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Time{}
+	}
+	return t.UTC()
+}
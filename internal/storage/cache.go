@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/enleur/shrink/internal/shortener"
+)
+
+// CachedStore is a read-through, size-bounded LRU cache of Resolve results
+// in front of another store. Positive entries live for at most ttl and never
+// past the link's own expiration; lookups that found nothing are cached for
+// negativeTTL. Links with a click limit, an activation window or a sliding
+// expiration are never cached, since every redirect has to be counted,
+// checked or renewed by the inner store. Redirects served from the cache are
+// not added to a link's click count.
+//
+// Writes made through the cache invalidate the local entry once they are
+// done, and a Resolve that read the inner store before such a write doesn't
+// cache what it read. Writes made by other replicas are only picked up once
+// the entry ages out, so ttl bounds how stale a redirect can be.
+type CachedStore struct {
+	inner       shortener.Store
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// writes counts invalidations, so that a Resolve can tell whether a
+	// write finished while it was reading the inner store.
+	writes uint64
+}
+
+type cacheEntry struct {
+	key       string
+	link      shortener.Link
+	err       error
+	expiresAt time.Time
+}
+
+func NewCachedStore(inner shortener.Store, size int, ttl, negativeTTL time.Duration) *CachedStore {
+	return &CachedStore{
+		inner:       inner,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+}
+
+func (s *CachedStore) Set(ctx context.Context, key string, link shortener.Link) error {
+	err := s.inner.Set(ctx, key, link)
+	s.invalidate(key)
+	return err
+}
+
+func (s *CachedStore) Create(ctx context.Context, key string, link shortener.Link) error {
+	err := s.inner.Create(ctx, key, link)
+	s.invalidate(key)
+	return err
+}
+
+func (s *CachedStore) CreateBatch(ctx context.Context, entries []shortener.LinkEntry) ([]error, error) {
+	errs, err := s.inner.CreateBatch(ctx, entries)
+	for _, entry := range entries {
+		s.invalidate(entry.Key)
+	}
+	return errs, err
+}
+
+func (s *CachedStore) Delete(ctx context.Context, key string) error {
+	err := s.inner.Delete(ctx, key)
+	s.invalidate(key)
+	return err
+}
+
+func (s *CachedStore) Update(ctx context.Context, key string, now time.Time, fn func(shortener.Link) (shortener.Link, error)) (shortener.Link, error) {
+	link, err := s.inner.Update(ctx, key, now, fn)
+	s.invalidate(key)
+	return link, err
+}
+
+func (s *CachedStore) Renew(ctx context.Context, key string, now, expiresAt time.Time) (shortener.Link, error) {
+	link, err := s.inner.Renew(ctx, key, now, expiresAt)
+	s.invalidate(key)
+	return link, err
+}
+
+// FindByURL is not cached; it only runs when shortening.
+func (s *CachedStore) FindByURL(ctx context.Context, url string) (string, time.Time, error) {
+	return s.inner.FindByURL(ctx, url)
+}
+
+// FindByURLs is not cached either.
+func (s *CachedStore) FindByURLs(ctx context.Context, urls []string) ([]shortener.URLMatch, error) {
+	return s.inner.FindByURLs(ctx, urls)
+}
+
+// Unlock is not cached; password-protected links never are.
+func (s *CachedStore) Unlock(ctx context.Context, key string, at time.Time) (shortener.Link, error) {
+	return s.inner.Unlock(ctx, key, at)
+}
+
+// Search is not cached.
+func (s *CachedStore) Search(ctx context.Context, filter shortener.LinkFilter, after string, limit int) ([]shortener.LinkEntry, error) {
+	return s.inner.Search(ctx, filter, after, limit)
+}
+
+// Scan is not cached.
+func (s *CachedStore) Scan(ctx context.Context, cursor string, limit int) ([]shortener.LinkEntry, string, error) {
+	return s.inner.Scan(ctx, cursor, limit)
+}
+
+// Get is not cached; redirects go through Resolve.
+func (s *CachedStore) Get(ctx context.Context, key string) (shortener.Link, error) {
+	return s.inner.Get(ctx, key)
+}
+
+// Resolve ages cache entries by at, the time the redirect is served at.
+func (s *CachedStore) Resolve(ctx context.Context, key string, at time.Time) (shortener.Link, error) {
+	if entry, ok := s.lookup(key, at); ok {
+		if entry.err != nil {
+			cacheLookupsTotal.WithLabelValues("negative_hit").Inc()
+			return shortener.Link{}, entry.err
+		}
+		cacheLookupsTotal.WithLabelValues("hit").Inc()
+		return entry.link, nil
+	}
+	cacheLookupsTotal.WithLabelValues("miss").Inc()
+
+	writes := s.writeCount()
+	link, err := s.inner.Resolve(ctx, key, at)
+	switch {
+	case err == nil && !link.Restricted():
+		expiresAt := at.Add(s.ttl)
+		if !link.ExpiresAt.IsZero() && link.ExpiresAt.Before(expiresAt) {
+			expiresAt = link.ExpiresAt
+		}
+		s.add(&cacheEntry{key: key, link: link, expiresAt: expiresAt}, writes, at)
+	case errors.Is(err, shortener.ErrNotFound) || errors.Is(err, shortener.ErrExpired) || errors.Is(err, shortener.ErrExhausted) ||
+		errors.Is(err, shortener.ErrDisabled):
+		if s.negativeTTL > 0 {
+			s.add(&cacheEntry{key: key, err: err, expiresAt: at.Add(s.negativeTTL)}, writes, at)
+		}
+	}
+
+	return link, err
+}
+
+func (s *CachedStore) lookup(key string, now time.Time) (*cacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if !now.Before(entry.expiresAt) {
+		s.remove(elem)
+		return nil, false
+	}
+
+	s.lru.MoveToFront(elem)
+	return entry, true
+}
+
+func (s *CachedStore) writeCount() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writes
+}
+
+// add caches entry unless a write finished since writes was read, in which
+// case entry may describe the link as it was before that write.
+func (s *CachedStore) add(entry *cacheEntry, writes uint64, now time.Time) {
+	if s.size <= 0 || !now.Before(entry.expiresAt) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writes != writes {
+		return
+	}
+
+	if elem, ok := s.entries[entry.key]; ok {
+		elem.Value = entry
+		s.lru.MoveToFront(elem)
+		return
+	}
+
+	s.entries[entry.key] = s.lru.PushFront(entry)
+	for s.lru.Len() > s.size {
+		s.remove(s.lru.Back())
+	}
+	cacheEntries.Set(float64(s.lru.Len()))
+}
+
+func (s *CachedStore) invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes++
+
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+}
+
+// remove must be called with s.mu held.
+func (s *CachedStore) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.entries, elem.Value.(*cacheEntry).key)
+	cacheEntries.Set(float64(s.lru.Len()))
+}
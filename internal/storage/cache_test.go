@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/enleur/shrink/internal/shortener"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStore counts reads reaching the wrapped store.
+type countingStore struct {
+	shortener.Store
+	reads int
+}
+
+func (s *countingStore) Resolve(ctx context.Context, key string, now time.Time) (shortener.Link, error) {
+	s.reads++
+	return s.Store.Resolve(ctx, key, now)
+}
+
+// pausingStore holds each Resolve, once it has read the wrapped store, until
+// release is closed.
+type pausingStore struct {
+	shortener.Store
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (s *pausingStore) Resolve(ctx context.Context, key string, now time.Time) (shortener.Link, error) {
+	link, err := s.Store.Resolve(ctx, key, now)
+	s.read <- struct{}{}
+	<-s.release
+	return link, err
+}
+
+func TestCachedStore(t *testing.T) {
+	ctx := context.Background()
+
+	memory := NewMemoryStore(0)
+	defer func() { _ = memory.Close() }()
+
+	t.Run("Serves Repeated Reads From Cache", func(t *testing.T) {
+		inner := &countingStore{Store: memory}
+		store := NewCachedStore(inner, 10, time.Minute, time.Minute)
+		hits := testutil.ToFloat64(cacheLookupsTotal.WithLabelValues("hit"))
+
+		assert.NoError(t, store.Set(ctx, "hot", testLink("https://example.com", time.Hour)))
+
+		for i := 0; i < 3; i++ {
+			link, err := store.Resolve(ctx, "hot", time.Now())
+			assert.NoError(t, err)
+			assert.Equal(t, "https://example.com", link.URL)
+		}
+
+		assert.Equal(t, 1, inner.reads)
+		assert.Equal(t, hits+2, testutil.ToFloat64(cacheLookupsTotal.WithLabelValues("hit")))
+	})
+
+	t.Run("Delete Invalidates", func(t *testing.T) {
+		store := NewCachedStore(memory, 10, time.Minute, time.Minute)
+
+		assert.NoError(t, store.Set(ctx, "takenDown", testLink("https://example.com", time.Hour)))
+		_, err := store.Resolve(ctx, "takenDown", time.Now())
+		assert.NoError(t, err)
+
+		assert.NoError(t, store.Delete(ctx, "takenDown"))
+		_, err = store.Resolve(ctx, "takenDown", time.Now())
+		assert.ErrorIs(t, err, shortener.ErrDisabled)
+	})
+
+	t.Run("Delete Racing Resolve", func(t *testing.T) {
+		inner := &pausingStore{Store: memory, read: make(chan struct{}), release: make(chan struct{})}
+		store := NewCachedStore(inner, 10, time.Minute, time.Minute)
+
+		assert.NoError(t, memory.Set(ctx, "raced", testLink("https://example.com", time.Hour)))
+
+		// The Resolve reads the link before the Delete and only caches it
+		// after the Delete is done.
+		resolved := make(chan error)
+		go func() {
+			_, err := store.Resolve(ctx, "raced", time.Now())
+			resolved <- err
+		}()
+		<-inner.read
+		assert.NoError(t, store.Delete(ctx, "raced"))
+		close(inner.release)
+		assert.NoError(t, <-resolved)
+
+		go func() { <-inner.read }()
+		_, err := store.Resolve(ctx, "raced", time.Now())
+		assert.ErrorIs(t, err, shortener.ErrDisabled)
+	})
+
+	t.Run("Respects Link Expiration", func(t *testing.T) {
+		store := NewCachedStore(memory, 10, time.Minute, 0)
+
+		assert.NoError(t, store.Set(ctx, "shortLived", testLink("https://example.com", 20*time.Millisecond)))
+
+		_, err := store.Resolve(ctx, "shortLived", time.Now())
+		assert.NoError(t, err)
+
+		time.Sleep(30 * time.Millisecond)
+
+		_, err = store.Resolve(ctx, "shortLived", time.Now())
+		assert.ErrorIs(t, err, shortener.ErrExpired)
+	})
+
+	t.Run("Ages Entries By Request Time", func(t *testing.T) {
+		inner := &countingStore{Store: memory}
+		store := NewCachedStore(inner, 10, time.Minute, 0)
+
+		assert.NoError(t, store.Set(ctx, "aged", testLink("https://example.com", time.Hour)))
+
+		start := time.Now()
+		for _, at := range []time.Duration{0, 30 * time.Second, 2 * time.Minute} {
+			_, err := store.Resolve(ctx, "aged", start.Add(at))
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 2, inner.reads)
+	})
+
+	t.Run("Caches Missing Keys", func(t *testing.T) {
+		inner := &countingStore{Store: memory}
+		store := NewCachedStore(inner, 10, time.Minute, time.Minute)
+
+		_, err := store.Resolve(ctx, "missing", time.Now())
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+		_, err = store.Resolve(ctx, "missing", time.Now())
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+		assert.Equal(t, 1, inner.reads)
+
+		assert.NoError(t, store.Create(ctx, "missing", shortener.Link{URL: "https://example.com", ExpiresAt: time.Now().Add(time.Minute)}))
+
+		link, err := store.Resolve(ctx, "missing", time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com", link.URL)
+	})
+
+	t.Run("Does Not Cache Limited Links", func(t *testing.T) {
+		inner := &countingStore{Store: memory}
+		store := NewCachedStore(inner, 10, time.Minute, 0)
+
+		assert.NoError(t, store.Create(ctx, "limited", shortener.Link{URL: "https://example.com", MaxClicks: 2}))
+
+		for i := 0; i < 2; i++ {
+			_, err := store.Resolve(ctx, "limited", time.Now())
+			assert.NoError(t, err)
+		}
+		_, err := store.Resolve(ctx, "limited", time.Now())
+		assert.ErrorIs(t, err, shortener.ErrExhausted)
+		assert.Equal(t, 3, inner.reads)
+	})
+
+	t.Run("Evicts Least Recently Used", func(t *testing.T) {
+		inner := &countingStore{Store: memory}
+		store := NewCachedStore(inner, 2, time.Minute, time.Minute)
+
+		for _, key := range []string{"a", "b", "c"} {
+			assert.NoError(t, memory.Set(ctx, key, testLink(key, time.Minute)))
+			_, err := store.Resolve(ctx, key, time.Now())
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 3, inner.reads)
+
+		_, err := store.Resolve(ctx, "c", time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 3, inner.reads)
+
+		_, err = store.Resolve(ctx, "a", time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 4, inner.reads)
+	})
+}
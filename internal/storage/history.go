@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/enleur/shrink/internal/shortener"
+)
+
+// historyRecord is the JSON form of a shortener.HistoryEntry, shared by the
+// stores that keep a link's history in a single field.
+type historyRecord struct {
+	Version   int64     `json:"version"`
+	URL       string    `json:"url"`
+	Actor     string    `json:"actor,omitempty"`
+	ChangedAt time.Time `json:"changedAt"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+func newHistoryRecords(history []shortener.HistoryEntry) []historyRecord {
+	if len(history) == 0 {
+		return nil
+	}
+	records := make([]historyRecord, len(history))
+	for i, entry := range history {
+		records[i] = historyRecord{
+			Version:   entry.Version,
+			URL:       entry.URL,
+			Actor:     entry.Actor,
+			ChangedAt: entry.ChangedAt.UTC(),
+			Reason:    entry.Reason,
+		}
+	}
+	return records
+}
+
+func historyEntries(records []historyRecord) []shortener.HistoryEntry {
+	if len(records) == 0 {
+		return nil
+	}
+	history := make([]shortener.HistoryEntry, len(records))
+	for i, record := range records {
+		history[i] = shortener.HistoryEntry(record)
+	}
+	return history
+}
+
+// encodeHistory returns the JSON form of history, or "" if it is empty.
+func encodeHistory(history []shortener.HistoryEntry) (string, error) {
+	if len(history) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(newHistoryRecords(history))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode link history: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeHistory reverses encodeHistory.
+func decodeHistory(data string) ([]shortener.HistoryEntry, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var records []historyRecord
+	if err := json.Unmarshal([]byte(data), &records); err != nil {
+		return nil, fmt.Errorf("failed to decode link history: %w", err)
+	}
+	return historyEntries(records), nil
+}
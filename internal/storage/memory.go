@@ -0,0 +1,384 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/enleur/shrink/internal/shortener"
+)
+
+// MemoryStore keeps links in process memory. It is meant for local
+// development and tests; data is lost when the process exits.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	items   map[string]shortener.Link
+	urls    map[string]string
+	counter uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// expired reports whether link has passed its expiration time.
+func expired(link shortener.Link, now time.Time) bool {
+	return !link.ExpiresAt.IsZero() && !now.Before(link.ExpiresAt)
+}
+
+// scanCursor is the cursor a key-ordered Scan returns after entries: the
+// last key of a full page, or empty once there are no more.
+func scanCursor(entries []shortener.LinkEntry, limit int) string {
+	if len(entries) < limit || len(entries) == 0 {
+		return ""
+	}
+	return entries[len(entries)-1].Key
+}
+
+// NewMemoryStore creates a MemoryStore. Expired entries are dropped lazily on
+// access and by a background sweep running every cleanupInterval; a
+// non-positive interval disables the sweep.
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		items: make(map[string]shortener.Link),
+		urls:  make(map[string]string),
+		done:  make(chan struct{}),
+	}
+
+	if cleanupInterval > 0 {
+		s.wg.Add(1)
+		go s.sweep(cleanupInterval)
+	}
+
+	return s
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, link shortener.Link) error {
+	s.mu.Lock()
+	s.put(key, link)
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemoryStore) Create(_ context.Context, key string, link shortener.Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.create(key, link, time.Now())
+}
+
+// CreateBatch creates every entry under a single lock.
+func (s *MemoryStore) CreateBatch(_ context.Context, entries []shortener.LinkEntry) ([]error, error) {
+	now := time.Now()
+	errs := make([]error, len(entries))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, entry := range entries {
+		errs[i] = s.create(entry.Key, entry.Link, now)
+	}
+
+	return errs, nil
+}
+
+// create stores link at key unless it holds a live link. It must be called
+// with s.mu held for writing.
+func (s *MemoryStore) create(key string, link shortener.Link, now time.Time) error {
+	link.Clicks, link.Version, link.Disabled, link.History = 0, 0, false, nil
+	if current, ok := s.items[key]; ok && !expired(current, now) {
+		return shortener.ErrAlreadyExists
+	}
+	s.put(key, link)
+
+	return nil
+}
+
+// Get returns the link at key. Expired links are returned until the sweep
+// or a write drops them.
+func (s *MemoryStore) Get(_ context.Context, key string) (shortener.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.items[key]
+	if !ok {
+		return shortener.Link{}, shortener.ErrNotFound
+	}
+	return link, nil
+}
+
+func (s *MemoryStore) Resolve(_ context.Context, key string, now time.Time) (shortener.Link, error) {
+	return s.resolve(key, now, false)
+}
+
+func (s *MemoryStore) Unlock(_ context.Context, key string, now time.Time) (shortener.Link, error) {
+	return s.resolve(key, now, true)
+}
+
+// resolve follows the link at key; password-protected links are only
+// followed when unlock is set.
+func (s *MemoryStore) resolve(key string, now time.Time, unlock bool) (shortener.Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.items[key]
+	if !ok {
+		return shortener.Link{}, shortener.ErrNotFound
+	}
+	if expired(link, now) {
+		s.delete(key, link)
+		return shortener.Link{}, shortener.ErrExpired
+	}
+	if link.Disabled {
+		return shortener.Link{}, shortener.ErrDisabled
+	}
+	if err := link.Active(now); err != nil {
+		return shortener.Link{}, err
+	}
+	if link.MaxClicks > 0 && link.Clicks >= link.MaxClicks {
+		return shortener.Link{}, shortener.ErrExhausted
+	}
+	if link.PasswordHash != "" && !unlock {
+		return shortener.Link{}, shortener.ErrPasswordRequired
+	}
+	link.Clicks++
+	if link.Slide > 0 {
+		link.ExpiresAt = link.Renewal(now.Add(link.Slide))
+	}
+	s.items[key] = link
+
+	return link, nil
+}
+
+func (s *MemoryStore) Update(_ context.Context, key string, now time.Time, fn func(shortener.Link) (shortener.Link, error)) (shortener.Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.items[key]
+	if !ok {
+		return shortener.Link{}, shortener.ErrNotFound
+	}
+	if expired(current, now) {
+		s.delete(key, current)
+		return shortener.Link{}, shortener.ErrExpired
+	}
+	if current.Disabled {
+		return shortener.Link{}, shortener.ErrDisabled
+	}
+
+	link, err := fn(current)
+	if err != nil {
+		return shortener.Link{}, err
+	}
+	current.URL = link.URL
+	current.ExpiresAt = link.ExpiresAt
+	current.RedirectStatus = link.RedirectStatus
+	current.History = link.History
+	current.Version++
+	s.put(key, current)
+
+	return current, nil
+}
+
+func (s *MemoryStore) Renew(_ context.Context, key string, now, expiresAt time.Time) (shortener.Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.items[key]
+	if !ok {
+		return shortener.Link{}, shortener.ErrNotFound
+	}
+	if expired(link, now) {
+		s.delete(key, link)
+		return shortener.Link{}, shortener.ErrExpired
+	}
+	if link.Disabled {
+		return shortener.Link{}, shortener.ErrDisabled
+	}
+	link.ExpiresAt = link.Renewal(expiresAt)
+	s.put(key, link)
+
+	return link, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.items[key]
+	if !ok {
+		return shortener.ErrNotFound
+	}
+	if link.Disabled {
+		return nil
+	}
+	link.Disabled = true
+	link.ExpiresAt = time.Time{}
+	link.Version++
+	s.put(key, link)
+
+	return nil
+}
+
+// FindByURL returns the key most recently written with value url, if that
+// key still holds it and is live.
+func (s *MemoryStore) FindByURL(_ context.Context, url string) (string, time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.findByURL(url, time.Now())
+}
+
+// FindByURLs looks every URL up under one read lock.
+func (s *MemoryStore) FindByURLs(_ context.Context, urls []string) ([]shortener.URLMatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	matches := make([]shortener.URLMatch, len(urls))
+	for i, url := range urls {
+		matches[i].Key, matches[i].ExpiresAt, matches[i].Err = s.findByURL(url, now)
+	}
+	return matches, nil
+}
+
+// findByURL must be called with s.mu held.
+func (s *MemoryStore) findByURL(url string, now time.Time) (string, time.Time, error) {
+	key, ok := s.urls[url]
+	if !ok {
+		return "", time.Time{}, shortener.ErrNotFound
+	}
+	link, ok := s.items[key]
+	if !ok || link.URL != url || !link.Shareable() || link.Disabled || expired(link, now) {
+		return "", time.Time{}, shortener.ErrNotFound
+	}
+
+	return key, link.ExpiresAt, nil
+}
+
+// Iterate visits a snapshot of the live entries and tombstones, so fn runs
+// without holding the store lock.
+func (s *MemoryStore) Iterate(ctx context.Context, fn func(key string, link shortener.Link) error) error {
+	now := time.Now()
+
+	s.mu.RLock()
+	snapshot := make(map[string]shortener.Link, len(s.items))
+	for key, link := range s.items {
+		if !expired(link, now) {
+			snapshot[key] = link
+		}
+	}
+	s.mu.RUnlock()
+
+	for key, link := range snapshot {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(key, link); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Search filters every live link; the memory store keeps no indexes.
+func (s *MemoryStore) Search(_ context.Context, filter shortener.LinkFilter, after string, limit int) ([]shortener.LinkEntry, error) {
+	now := time.Now()
+
+	s.mu.RLock()
+	var entries []shortener.LinkEntry
+	for key, link := range s.items {
+		if key > after && !expired(link, now) && !link.Disabled && filter.Matches(link) {
+			entries = append(entries, shortener.LinkEntry{Key: key, Link: link})
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(entries, func(a, b shortener.LinkEntry) int { return strings.Compare(a.Key, b.Key) })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// Scan lists links in key order, the cursor being the last key listed.
+func (s *MemoryStore) Scan(ctx context.Context, cursor string, limit int) ([]shortener.LinkEntry, string, error) {
+	entries, err := s.Search(ctx, shortener.LinkFilter{}, cursor, limit)
+	return entries, scanCursor(entries, limit), err
+}
+
+func (s *MemoryStore) Next(context.Context) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.counter++
+	return s.counter, nil
+}
+
+// Len returns the number of stored entries, including expired ones that
+// have not been evicted yet.
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.items)
+}
+
+func (s *MemoryStore) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.wg.Wait()
+	return nil
+}
+
+func (s *MemoryStore) sweep(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.evictExpired()
+		}
+	}
+}
+
+func (s *MemoryStore) evictExpired() {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, link := range s.items {
+		if expired(link, now) {
+			s.delete(key, link)
+		}
+	}
+}
+
+// put stores link under key and points the URL index at it. It must be
+// called with s.mu held for writing.
+func (s *MemoryStore) put(key string, link shortener.Link) {
+	if current, ok := s.items[key]; ok {
+		s.delete(key, current)
+	}
+	s.items[key] = link
+	if link.Shareable() && !link.Disabled {
+		s.urls[link.URL] = key
+	}
+}
+
+// delete removes key and its URL index entry. It must be called with s.mu
+// held for writing.
+func (s *MemoryStore) delete(key string, link shortener.Link) {
+	delete(s.items, key)
+	if s.urls[link.URL] == key {
+		delete(s.urls, link.URL)
+	}
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	cacheLookupsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "Total number of link cache lookups by result (hit, negative_hit, miss)",
+		},
+		[]string{"result"},
+	)
+	cacheEntries = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cache_entries",
+			Help: "Number of entries in the link cache",
+		},
+	)
+)
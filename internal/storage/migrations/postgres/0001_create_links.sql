@@ -0,0 +1,7 @@
+CREATE TABLE links (
+    code       TEXT PRIMARY KEY,
+    url        TEXT NOT NULL,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX links_expires_at_idx ON links (expires_at);
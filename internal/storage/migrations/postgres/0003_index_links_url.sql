@@ -0,0 +1,2 @@
+-- A hash index, since long URLs can exceed the btree row size limit.
+CREATE INDEX links_url_idx ON links USING hash (url);
@@ -0,0 +1,2 @@
+ALTER TABLE links ADD COLUMN not_before TIMESTAMPTZ;
+ALTER TABLE links ADD COLUMN not_after TIMESTAMPTZ;
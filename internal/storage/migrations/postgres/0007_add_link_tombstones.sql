@@ -0,0 +1 @@
+ALTER TABLE links ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1 @@
+ALTER TABLE links ADD COLUMN history TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE links ADD COLUMN slide_ms BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN max_expires_at TIMESTAMPTZ;
@@ -0,0 +1 @@
+CREATE INDEX links_url_idx ON links (url);
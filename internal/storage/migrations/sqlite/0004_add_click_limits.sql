@@ -0,0 +1,2 @@
+ALTER TABLE links ADD COLUMN max_clicks BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN clicks BIGINT NOT NULL DEFAULT 0;
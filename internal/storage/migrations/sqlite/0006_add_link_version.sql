@@ -0,0 +1 @@
+ALTER TABLE links ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE links ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE links ADD COLUMN creator TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+-- Tags are stored as ",a,b," so a single tag can be matched with LIKE.
+-- domain is the destination host, filled in on write; rows written before
+-- it existed are left empty and matched by the store reading their URL.
+ALTER TABLE links ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN domain TEXT NOT NULL DEFAULT '';
+CREATE INDEX links_domain_idx ON links (domain);
+CREATE INDEX links_creator_idx ON links (creator);
+CREATE INDEX links_created_at_idx ON links (created_at);
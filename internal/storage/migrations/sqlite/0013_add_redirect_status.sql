@@ -0,0 +1,2 @@
+-- 0 leaves the redirect status to the server's default.
+ALTER TABLE links ADD COLUMN redirect_status INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/enleur/shrink/internal/config"
+	"github.com/enleur/shrink/internal/shortener"
+)
+
+const (
+	BackendRedis  = "redis"
+	BackendSQL    = "sql"
+	BackendBolt   = "bolt"
+	BackendMemory = "memory"
+)
+
+// Backend is a link store opened by Open. The caller owns it and must Close
+// it on shutdown.
+type Backend interface {
+	shortener.Store
+	shortener.Iterator
+	shortener.Counter
+	io.Closer
+}
+
+// Open creates the store selected by conf.Store.Backend. SQL migrations are
+// applied before it returns.
+func Open(conf *config.Config) (Backend, error) {
+	switch conf.Store.Backend {
+	case BackendRedis:
+		redis, err := NewRedisStore(conf.Redis)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init redis store: %w", err)
+		}
+		return redis, nil
+	case BackendSQL:
+		sql, err := NewSQLStore(conf.SQL.Driver, conf.SQL.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init sql store: %w", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := sql.Migrate(ctx); err != nil {
+			_ = sql.Close()
+			return nil, fmt.Errorf("failed to migrate sql store: %w", err)
+		}
+		return sql, nil
+	case BackendBolt:
+		bolt, err := NewBoltStore(conf.Bolt.DataDir, conf.Bolt.SweepInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to init bolt store: %w", err)
+		}
+		return bolt, nil
+	case BackendMemory:
+		return NewMemoryStore(conf.Memory.CleanupInterval), nil
+	default:
+		return nil, fmt.Errorf("unknown store backend %q", conf.Store.Backend)
+	}
+}
@@ -2,25 +2,68 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/enleur/shrink/internal/config"
+	"github.com/enleur/shrink/internal/shortener"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
+// scanCount is the COUNT hint for SCAN; it bounds the work Redis does per
+// call so iterating a large keyspace never blocks the server.
+const scanCount = 500
+
+// codeCounterKey holds the short code counter, under the key prefix. Short
+// codes never contain a colon, so it can't clash with a link.
+const codeCounterKey = "counter:codes"
+
+// RedisStore keeps each link as a hash under the configured prefix followed
+// by the short code, with the link's expiration as the key TTL. The hash
+// carries a schema version in its v field; links written by earlier
+// versions, a string key holding the URL, are upgraded when first touched.
+// Keys under the prefix whose remainder contains a colon hold service state
+// rather than links.
+//
+// Search goes through index sorted sets, one for every link and one per
+// tag, creator and destination domain. Members all have score 0, so the
+// sets are ordered by short code. Like the URL hints, index entries are
+// written after the link and checked against it on read; entries left
+// behind by links that changed are dropped when a search comes across them.
+// Delete removes a link's entries, and the entries of links that expire are
+// also kept in an expiry set, scored by expiration, which every Create
+// trims a batch of expired entries from.
 type RedisStore struct {
-	client *redis.Client
+	client redis.UniversalClient
+	prefix string
 }
 
-func NewRedisStore(addr string, db int) (*RedisStore, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr: addr,
-		DB:   db,
-	})
+func NewRedisStore(conf config.RedisConfig) (*RedisStore, error) {
+	client, err := newRedisClient(conf)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := redisotel.InstrumentTracing(client); err != nil {
+		_ = client.Close()
 		return nil, err
 	}
 
@@ -28,22 +71,1289 @@ func NewRedisStore(addr string, db int) (*RedisStore, error) {
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &RedisStore{client: client}, nil
+	return &RedisStore{client: client, prefix: conf.KeyPrefix}, nil
+}
+
+func newRedisClient(conf config.RedisConfig) (redis.UniversalClient, error) {
+	if len(conf.Addresses) == 0 {
+		return nil, errors.New("no Redis address configured")
+	}
+
+	tlsConfig, err := newRedisTLSConfig(conf.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            conf.Addresses,
+		DB:               conf.DB,
+		Username:         conf.Username,
+		Password:         conf.Password,
+		MasterName:       conf.SentinelMaster,
+		SentinelUsername: conf.SentinelUsername,
+		SentinelPassword: conf.SentinelPassword,
+		TLSConfig:        tlsConfig,
+	}
+
+	switch conf.Mode {
+	case RedisModeStandalone, "":
+		if len(conf.Addresses) > 1 {
+			return nil, errors.New("standalone Redis mode takes a single address")
+		}
+		return redis.NewClient(opts.Simple()), nil
+	case RedisModeSentinel:
+		if conf.SentinelMaster == "" {
+			return nil, errors.New("sentinel Redis mode requires a master name")
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case RedisModeCluster:
+		if conf.DB != 0 {
+			return nil, errors.New("cluster Redis mode does not support selecting a DB")
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("unknown Redis mode %q", conf.Mode)
+	}
+}
+
+func newRedisTLSConfig(conf config.RedisTLSConfig) (*tls.Config, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+
+	if conf.CAFile != "" {
+		ca, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in Redis CA file %s", conf.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func (s *RedisStore) key(code string) string {
+	return s.prefix + code
+}
+
+// urlKey holds the code last written with url. It is a hint, checked
+// against the link key on read, since the two keys may live on different
+// cluster nodes and can't be written atomically.
+func (s *RedisStore) urlKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return s.key("url:" + hex.EncodeToString(sum[:]))
+}
+
+// indexKey is the search index for the given kind and value; an empty kind
+// is the index of every link.
+func (s *RedisStore) indexKey(kind, value string) string {
+	if kind == "" {
+		return s.key("index:all")
+	}
+	return s.key("index:" + kind + ":" + value)
+}
+
+// indexKeys lists the search indexes link belongs in.
+func (s *RedisStore) indexKeys(link shortener.Link) []string {
+	indexes := []string{s.indexKey("", "")}
+	for _, tag := range link.Tags {
+		indexes = append(indexes, s.indexKey("tag", tag))
+	}
+	if link.Creator != "" {
+		indexes = append(indexes, s.indexKey("creator", link.Creator))
+	}
+	if domain := link.Domain(); domain != "" {
+		indexes = append(indexes, s.indexKey("domain", domain))
+	}
+	return indexes
+}
+
+// expiryKey holds the index entries of links that expire, scored by their
+// expiration in unix ms.
+func (s *RedisStore) expiryKey() string {
+	return s.key("index:expiry")
+}
+
+// expiryMember names the entry for code in index within the expiry set.
+// Short codes never contain a colon, so it splits back at the first one.
+func expiryMember(code, index string) string {
+	return code + ":" + index
+}
+
+// index adds the link at key to the search indexes, and points its URL hint
+// at it unless the link is restricted. ttl is the link's, 0 for none.
+func (s *RedisStore) index(ctx context.Context, pipe redis.Pipeliner, key string, link shortener.Link, ttl time.Duration) []redis.Cmder {
+	var cmds []redis.Cmder
+	for _, index := range s.indexKeys(link) {
+		cmds = append(cmds, pipe.ZAdd(ctx, index, redis.Z{Member: key}))
+		if link.ExpiresAt.IsZero() {
+			cmds = append(cmds, pipe.ZRem(ctx, s.expiryKey(), expiryMember(key, index)))
+		} else {
+			cmds = append(cmds, pipe.ZAdd(ctx, s.expiryKey(), redis.Z{
+				Score: float64(link.ExpiresAt.UnixMilli()), Member: expiryMember(key, index),
+			}))
+		}
+	}
+	if link.Shareable() {
+		cmds = append(cmds, pipe.Set(ctx, s.urlKey(link.URL), key, ttl))
+	}
+	return cmds
+}
+
+// unindex removes the link at key from the search indexes.
+func (s *RedisStore) unindex(ctx context.Context, pipe redis.Pipeliner, key string, link shortener.Link) {
+	for _, index := range s.indexKeys(link) {
+		pipe.ZRem(ctx, index, key)
+		pipe.ZRem(ctx, s.expiryKey(), expiryMember(key, index))
+	}
+}
+
+// trimCount is how many expired index entries trimIndexes drops at a time.
+const trimCount = 100
+
+// trimIndexes drops up to trimCount index entries whose link had expired by
+// now. Entries of links that were renewed or slid since they were indexed
+// are rescored to the link's current expiration instead.
+func (s *RedisStore) trimIndexes(ctx context.Context, now time.Time) error {
+	members, err := s.client.ZRangeArgs(ctx, redis.ZRangeArgs{
+		Key: s.expiryKey(), Start: "-inf", Stop: strconv.FormatInt(now.UnixMilli(), 10), ByScore: true, Count: trimCount,
+	}).Result()
+	if err != nil || len(members) == 0 {
+		return err
+	}
+
+	replies := make(map[string]*redis.Cmd)
+	pipe := s.client.Pipeline()
+	for _, member := range members {
+		code, _, _ := strings.Cut(member, ":")
+		if replies[code] == nil {
+			replies[code] = readScript.Eval(ctx, pipe, []string{s.key(code), s.metaKey(code)})
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	pipe = s.client.Pipeline()
+	for _, member := range members {
+		code, index, _ := strings.Cut(member, ":")
+		res, err := replies[code].Slice()
+		gone := errors.Is(err, redis.Nil)
+		if err != nil && !gone {
+			return err
+		}
+		var link shortener.Link
+		if !gone {
+			if link, _, err = readReply(res); err != nil {
+				return fmt.Errorf("failed to read link %q: %w", code, err)
+			}
+		}
+		switch {
+		case gone, link.Disabled, expired(link, now):
+			pipe.ZRem(ctx, index, code)
+			pipe.ZRem(ctx, s.expiryKey(), member)
+		case link.ExpiresAt.IsZero():
+			pipe.ZRem(ctx, s.expiryKey(), member)
+		default:
+			pipe.ZAdd(ctx, s.expiryKey(), redis.Z{Score: float64(link.ExpiresAt.UnixMilli()), Member: member})
+		}
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// metaKey held a legacy link's click limit and count in a hash. Its hash tag
+// is the whole link key, so both keys share a cluster slot and a script can
+// upgrade them atomically.
+func (s *RedisStore) metaKey(code string) string {
+	return "{" + s.key(code) + "}:meta"
+}
+
+// redisSchemaVersion is the v field of link hashes written by this version.
+const redisSchemaVersion = 1
+
+// upgradeLua is prepended to every script that touches a link. It turns a
+// link stored in the legacy layout, a string key holding the URL next to a
+// meta hash, into a link hash with the same TTL. upgrade returns 1 if it
+// converted the key.
+const upgradeLua = `
+local function upgrade(key, meta)
+	if redis.call('TYPE', key).ok ~= 'string' then
+		return 0
+	end
+	local url = redis.call('GET', key)
+	local ttl = redis.call('PTTL', key)
+	local fields = redis.call('HGETALL', meta)
+	redis.call('DEL', key, meta)
+	redis.call('HSET', key, 'v', 1, 'url', url, unpack(fields))
+	if ttl > 0 then
+		redis.call('PEXPIRE', key, ttl)
+	end
+	return 1
+end
+`
+
+// renewLua is prepended to the scripts that renew links. renew pushes the
+// link's expiration out to target, in unix ms as of now, but no further than
+// its maxExpiresAt, and never brings it forward. Links without a TTL are
+// left alone. It returns the key's PTTL afterwards.
+const renewLua = `
+local function renew(key, pttl, now, target)
+	if pttl < 0 then
+		return pttl
+	end
+	local max = tonumber(redis.call('HGET', key, 'maxExpiresAt') or '0')
+	if max > 0 and target > max then
+		target = max
+	end
+	if target - now > pttl then
+		pttl = target - now
+		redis.call('PEXPIRE', key, pttl)
+	end
+	return pttl
+end
+`
+
+// expiredLua is prepended to the scripts that judge expiration by the
+// caller's time rather than by whether Redis has dropped the key yet.
+// expired reports whether a key with the given PTTL has expired by now,
+// clock being the caller's wall clock when it ran the script, both in unix
+// ms.
+const expiredLua = `
+local function expired(pttl, now, clock)
+	return pttl >= 0 and clock + pttl <= now
+end
+`
+
+// linkFields encodes link as the fields of a link hash.
+func linkFields(link shortener.Link) ([]any, error) {
+	disabled := 0
+	if link.Disabled {
+		disabled = 1
+	}
+	history, err := encodeHistory(link.History)
+	if err != nil {
+		return nil, err
+	}
+	return []any{
+		"v", redisSchemaVersion,
+		"url", link.URL,
+		"slide", link.Slide.Milliseconds(),
+		"maxExpiresAt", unixMilli(link.MaxExpiresAt),
+		"createdAt", unixMilli(link.CreatedAt),
+		"creator", link.Creator,
+		"maxClicks", link.MaxClicks,
+		"clicks", link.Clicks,
+		"notBefore", unixMilli(link.NotBefore),
+		"notAfter", unixMilli(link.NotAfter),
+		"version", link.Version,
+		"disabled", disabled,
+		"password", link.PasswordHash,
+		"history", history,
+		"title", link.Title,
+		"description", link.Description,
+		"tags", strings.Join(link.Tags, ","),
+		"redirect", link.RedirectStatus,
+	}, nil
+}
+
+// decodeLink decodes a link hash as returned by HGETALL, along with the
+// key's PTTL. Hashes written by a newer schema are refused.
+func decodeLink(v any, pttl int64) (shortener.Link, error) {
+	values, _ := v.([]any)
+	fields := make(map[string]string, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		field, _ := values[i].(string)
+		fields[field], _ = values[i+1].(string)
+	}
+	if version := hashInt(fields["v"]); version > redisSchemaVersion {
+		return shortener.Link{}, fmt.Errorf("link has unsupported schema version %d", version)
+	}
+
+	history, err := decodeHistory(fields["history"])
+	if err != nil {
+		return shortener.Link{}, err
+	}
+
+	return shortener.Link{
+		URL:            fields["url"],
+		ExpiresAt:      ttlExpiresAt(time.Duration(pttl) * time.Millisecond),
+		Slide:          time.Duration(hashInt(fields["slide"])) * time.Millisecond,
+		MaxExpiresAt:   fromUnixMilli(hashInt(fields["maxExpiresAt"])),
+		CreatedAt:      fromUnixMilli(hashInt(fields["createdAt"])),
+		Creator:        fields["creator"],
+		MaxClicks:      hashInt(fields["maxClicks"]),
+		Clicks:         hashInt(fields["clicks"]),
+		NotBefore:      fromUnixMilli(hashInt(fields["notBefore"])),
+		NotAfter:       fromUnixMilli(hashInt(fields["notAfter"])),
+		Version:        hashInt(fields["version"]),
+		Disabled:       fields["disabled"] == "1",
+		PasswordHash:   fields["password"],
+		History:        history,
+		Title:          fields["title"],
+		Description:    fields["description"],
+		Tags:           splitTags(fields["tags"]),
+		RedirectStatus: int(hashInt(fields["redirect"])),
+	}, nil
+}
+
+func splitTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// hashInt parses a link hash field, a missing field meaning 0.
+func hashInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// ttlMillis converts an expiration time into the TTL a key is given, 0 for
+// none. A link that is already due still gets stored, and expires at once.
+func ttlMillis(expiresAt time.Time) int64 {
+	if expiresAt.IsZero() {
+		return 0
+	}
+	return max(time.Until(expiresAt).Milliseconds(), 1)
+}
+
+// Set replaces the link key, and any legacy meta hash, in one transaction.
+func (s *RedisStore) Set(ctx context.Context, key string, link shortener.Link) error {
+	fields, err := linkFields(link)
+	if err != nil {
+		return err
+	}
+
+	ttl := ttlMillis(link.ExpiresAt)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.key(key), s.metaKey(key))
+		pipe.HSet(ctx, s.key(key), fields...)
+		if ttl > 0 {
+			pipe.PExpire(ctx, s.key(key), time.Duration(ttl)*time.Millisecond)
+		}
+		return nil
+	})
+	if err != nil || link.Disabled {
+		return err
+	}
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.index(ctx, pipe, key, link, time.Duration(ttl)*time.Millisecond)
+		return nil
+	})
+	return err
+}
+
+// createScript writes the link hash if the key is free. KEYS: link, meta.
+// ARGV: ttl in ms (0 for none), then the hash fields.
+var createScript = redis.NewScript(upgradeLua + `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('DEL', KEYS[2])
+redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+if tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+func (s *RedisStore) Create(ctx context.Context, key string, link shortener.Link) error {
+	link.Clicks, link.Version, link.Disabled, link.History = 0, 0, false, nil
+	fields, err := linkFields(link)
+	if err != nil {
+		return err
+	}
+	ttl := ttlMillis(link.ExpiresAt)
+
+	args := append([]any{ttl}, fields...)
+	ok, err := createScript.Run(ctx, s.client, []string{s.key(key), s.metaKey(key)}, args...).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return shortener.ErrAlreadyExists
+	}
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.index(ctx, pipe, key, link, time.Duration(ttl)*time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The link is stored either way; a failed trim is left to the next one.
+	_ = s.trimIndexes(ctx, time.Now())
+	return nil
+}
+
+// CreateBatch runs createScript for every entry in one pipeline, then
+// writes the URL hints and search indexes of the links it created in a
+// second one. The script is sent with EVAL rather than EVALSHA, since a
+// pipeline can't fall back when the server doesn't have it cached. Each
+// entry is atomic on its own; the batch as a whole is not, and as with
+// Create an entry whose indexes couldn't be written reports that error.
+func (s *RedisStore) CreateBatch(ctx context.Context, entries []shortener.LinkEntry) ([]error, error) {
+	errs := make([]error, len(entries))
+	cmds := make([]*redis.Cmd, len(entries))
+	ttls := make([]int64, len(entries))
+	pipe := s.client.Pipeline()
+	for i, entry := range entries {
+		link := entry.Link
+		link.Clicks, link.Version, link.Disabled, link.History = 0, 0, false, nil
+		fields, err := linkFields(link)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		ttls[i] = ttlMillis(link.ExpiresAt)
+		args := append([]any{ttls[i]}, fields...)
+		cmds[i] = createScript.Eval(ctx, pipe, []string{s.key(entry.Key), s.metaKey(entry.Key)}, args...)
+	}
+	// Failed commands are reported per entry below.
+	if _, err := pipe.Exec(ctx); err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+
+	indexes := s.client.Pipeline()
+	indexCmds := make([][]redis.Cmder, len(entries))
+	for i, entry := range entries {
+		if cmds[i] == nil {
+			continue
+		}
+		ok, err := cmds[i].Int()
+		switch {
+		case err != nil:
+			errs[i] = err
+		case ok == 0:
+			errs[i] = shortener.ErrAlreadyExists
+		default:
+			indexCmds[i] = s.index(ctx, indexes, entry.Key, entry.Link, time.Duration(ttls[i])*time.Millisecond)
+		}
+	}
+	if indexes.Len() == 0 {
+		return errs, nil
+	}
+	if _, err := indexes.Exec(ctx); err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	for i, cmds := range indexCmds {
+		for _, cmd := range cmds {
+			if err := cmd.Err(); err != nil {
+				errs[i] = err
+				break
+			}
+		}
+	}
+	_ = s.trimIndexes(ctx, time.Now())
+	return errs, nil
+}
+
+// readScript returns a link's PTTL and hash, or nil if there is no link.
+// KEYS: link, meta.
+var readScript = redis.NewScript(upgradeLua + `
+upgrade(KEYS[1], KEYS[2])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+	return false
+end
+return {ttl, redis.call('HGETALL', KEYS[1])}
+`)
+
+// readLink reads a link in one round trip, whether it is live or not. It
+// also returns the link's PTTL, which is negative if it never expires.
+func (s *RedisStore) readLink(ctx context.Context, key string) (shortener.Link, time.Duration, error) {
+	res, err := readScript.Run(ctx, s.client, []string{s.key(key), s.metaKey(key)}).Slice()
+	if errors.Is(err, redis.Nil) {
+		return shortener.Link{}, 0, shortener.ErrNotFound
+	}
+	if err != nil {
+		return shortener.Link{}, 0, err
+	}
+	return readReply(res)
+}
+
+func readReply(res []any) (shortener.Link, time.Duration, error) {
+	if len(res) != 2 {
+		return shortener.Link{}, 0, fmt.Errorf("unexpected read reply: %v", res)
+	}
+	pttl, _ := res[0].(int64)
+	link, err := decodeLink(res[1], pttl)
+	return link, time.Duration(pttl) * time.Millisecond, err
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) (shortener.Link, error) {
+	link, _, err := s.readLink(ctx, key)
+	return link, err
+}
+
+// resolveScript reads a link and, inside its activation window, counts the
+// click and renews a sliding link. KEYS: link, meta. ARGV: now in unix ms,
+// 1 to follow a password-protected link, the wall clock in unix ms. Returns
+// nil for a missing link, otherwise a status, the PTTL and the link hash.
+// The status is 1 if served, 0 if the click limit was reached, 2 before the
+// window, 3 after it or once expired, 4 for a tombstone and 5 if a password
+// is required.
+var resolveScript = redis.NewScript(upgradeLua + renewLua + expiredLua + `
+upgrade(KEYS[1], KEYS[2])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+	return false
+end
+local link = redis.call('HMGET', KEYS[1], 'maxClicks', 'clicks', 'notBefore', 'notAfter', 'disabled', 'slide', 'password')
+local max = tonumber(link[1] or '0')
+local clicks = tonumber(link[2] or '0')
+local notBefore = tonumber(link[3] or '0')
+local notAfter = tonumber(link[4] or '0')
+local now = tonumber(ARGV[1])
+local status = 1
+if link[5] == '1' then
+	status = 4
+elseif expired(ttl, now, tonumber(ARGV[3])) then
+	status = 3
+elseif notBefore > 0 and now < notBefore then
+	status = 2
+elseif notAfter > 0 and now >= notAfter then
+	status = 3
+elseif max > 0 and clicks >= max then
+	status = 0
+elseif (link[7] or '') ~= '' and ARGV[2] ~= '1' then
+	status = 5
+else
+	redis.call('HINCRBY', KEYS[1], 'clicks', 1)
+	local slide = tonumber(link[6] or '0')
+	if slide > 0 then
+		ttl = renew(KEYS[1], ttl, now, now + slide)
+	end
+end
+return {status, ttl, redis.call('HGETALL', KEYS[1])}
+`)
+
+// Resolve reads the link, counts the click and renews a sliding link in one
+// script call, so it costs a single round trip.
+func (s *RedisStore) Resolve(ctx context.Context, key string, now time.Time) (shortener.Link, error) {
+	return s.resolve(ctx, key, now, false)
 }
 
-func (s *RedisStore) Set(ctx context.Context, key, value string, expiration time.Duration) error {
-	return s.client.Set(ctx, key, value, expiration).Err()
+func (s *RedisStore) Unlock(ctx context.Context, key string, now time.Time) (shortener.Link, error) {
+	return s.resolve(ctx, key, now, true)
 }
 
-func (s *RedisStore) Get(ctx context.Context, key string) (string, error) {
-	val, err := s.client.Get(ctx, key).Result()
+// resolve follows the link at key; password-protected links are only
+// followed when unlock is set.
+func (s *RedisStore) resolve(ctx context.Context, key string, now time.Time, unlock bool) (shortener.Link, error) {
+	flag := 0
+	if unlock {
+		flag = 1
+	}
+	res, err := resolveScript.Run(ctx, s.client, []string{s.key(key), s.metaKey(key)},
+		now.UnixMilli(), flag, time.Now().UnixMilli(),
+	).Slice()
 	if errors.Is(err, redis.Nil) {
-		return "", fmt.Errorf("key not found")
+		return shortener.Link{}, shortener.ErrNotFound
+	}
+	if err != nil {
+		return shortener.Link{}, err
 	}
-	return val, err
+	if len(res) != 3 {
+		return shortener.Link{}, fmt.Errorf("unexpected resolve reply: %v", res)
+	}
+
+	switch status, _ := res[0].(int64); status {
+	case 0:
+		return shortener.Link{}, shortener.ErrExhausted
+	case 2:
+		return shortener.Link{}, shortener.ErrNotYetActive
+	case 3:
+		return shortener.Link{}, shortener.ErrExpired
+	case 4:
+		return shortener.Link{}, shortener.ErrDisabled
+	case 5:
+		return shortener.Link{}, shortener.ErrPasswordRequired
+	}
+
+	link, _, err := readReply(res[1:])
+	return link, err
+}
+
+// FindByURL follows the URL hint and confirms the link key still holds url.
+func (s *RedisStore) FindByURL(ctx context.Context, url string) (string, time.Time, error) {
+	key, err := s.client.Get(ctx, s.urlKey(url)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", time.Time{}, shortener.ErrNotFound
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	link, _, err := s.readLink(ctx, key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if link.URL != url || !link.Shareable() || link.Disabled {
+		return "", time.Time{}, shortener.ErrNotFound
+	}
+	return key, link.ExpiresAt, nil
+}
+
+// FindByURLs reads every URL hint in one pipeline, then every link they
+// point at in a second one, and checks them as FindByURL does.
+func (s *RedisStore) FindByURLs(ctx context.Context, urls []string) ([]shortener.URLMatch, error) {
+	matches := make([]shortener.URLMatch, len(urls))
+	for i := range matches {
+		matches[i].Err = shortener.ErrNotFound
+	}
+	if len(urls) == 0 {
+		return matches, nil
+	}
+
+	hints := make([]*redis.StringCmd, len(urls))
+	pipe := s.client.Pipeline()
+	for i, url := range urls {
+		hints[i] = pipe.Get(ctx, s.urlKey(url))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	keys := make([]string, len(urls))
+	replies := make([]*redis.Cmd, len(urls))
+	pipe = s.client.Pipeline()
+	for i, hint := range hints {
+		key, err := hint.Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+		replies[i] = readScript.Eval(ctx, pipe, []string{s.key(key), s.metaKey(key)})
+	}
+	if pipe.Len() == 0 {
+		return matches, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	for i, reply := range replies {
+		if reply == nil {
+			continue
+		}
+		res, err := reply.Slice()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		link, _, err := readReply(res)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read link %q: %w", keys[i], err)
+		}
+		if link.URL == urls[i] && link.Shareable() && !link.Disabled {
+			matches[i] = shortener.URLMatch{Key: keys[i], ExpiresAt: link.ExpiresAt}
+		}
+	}
+	return matches, nil
+}
+
+// updateScript writes a new URL, TTL and redirect status if the link's
+// version is still the one the caller read. KEYS: link, meta. ARGV: expected
+// version, url, ttl in ms, -1 to keep the current one and 0 for none,
+// history, redirect status, now and the wall clock in unix ms. Returns -1
+// for a missing link, -2 for an expired one, 0 if the version changed and 1
+// once written.
+var updateScript = redis.NewScript(upgradeLua + expiredLua + `
+upgrade(KEYS[1], KEYS[2])
+local pttl = redis.call('PTTL', KEYS[1])
+if pttl == -2 then
+	return -1
+end
+if expired(pttl, tonumber(ARGV[6]), tonumber(ARGV[7])) then
+	return -2
+end
+if tonumber(redis.call('HGET', KEYS[1], 'version') or '0') ~= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'url', ARGV[2], 'history', ARGV[4], 'redirect', ARGV[5])
+redis.call('HINCRBY', KEYS[1], 'version', 1)
+local ttl = tonumber(ARGV[3])
+if ttl == 0 then
+	redis.call('PERSIST', KEYS[1])
+elseif ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// Update only writes the link if its version is still the one fn saw, and
+// starts over otherwise. Clicks don't change the version, so redirects
+// don't interfere.
+func (s *RedisStore) Update(ctx context.Context, key string, now time.Time, fn func(shortener.Link) (shortener.Link, error)) (shortener.Link, error) {
+	for {
+		current, pttl, err := s.readLink(ctx, key)
+		if err != nil {
+			return shortener.Link{}, err
+		}
+		if expired(current, now) {
+			return shortener.Link{}, shortener.ErrExpired
+		}
+		if current.Disabled {
+			return shortener.Link{}, shortener.ErrDisabled
+		}
+		link, err := fn(current)
+		if err != nil {
+			return shortener.Link{}, err
+		}
+
+		arg, ttl := int64(-1), pttl
+		if !link.ExpiresAt.Equal(current.ExpiresAt) {
+			arg = ttlMillis(link.ExpiresAt)
+			ttl = time.Duration(arg) * time.Millisecond
+		}
+
+		history, err := encodeHistory(link.History)
+		if err != nil {
+			return shortener.Link{}, err
+		}
+
+		ok, err := updateScript.Run(ctx, s.client, []string{s.key(key), s.metaKey(key)},
+			current.Version, link.URL, arg, history, link.RedirectStatus, now.UnixMilli(), time.Now().UnixMilli(),
+		).Int()
+		if err != nil {
+			return shortener.Link{}, err
+		}
+		switch ok {
+		case -1:
+			return shortener.Link{}, shortener.ErrNotFound
+		case -2:
+			return shortener.Link{}, shortener.ErrExpired
+		case 0:
+			continue
+		}
+
+		current.URL = link.URL
+		current.ExpiresAt = link.ExpiresAt
+		current.RedirectStatus = link.RedirectStatus
+		current.History = link.History
+		current.Version++
+		_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			s.index(ctx, pipe, key, current, max(ttl, 0))
+			return nil
+		})
+		if err != nil {
+			return shortener.Link{}, err
+		}
+		return current, nil
+	}
+}
+
+// renewScript renews a live link. KEYS: link, meta. ARGV: now, the
+// requested expiration and the wall clock, in unix ms. Returns nil for a
+// missing link, otherwise a status, the PTTL and the link hash. The status
+// is 1 if renewed, 3 once expired and 4 for a tombstone.
+var renewScript = redis.NewScript(upgradeLua + renewLua + expiredLua + `
+upgrade(KEYS[1], KEYS[2])
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+	return false
+end
+if redis.call('HGET', KEYS[1], 'disabled') == '1' then
+	return {4, ttl, {}}
+end
+if expired(ttl, tonumber(ARGV[1]), tonumber(ARGV[3])) then
+	return {3, ttl, {}}
+end
+ttl = renew(KEYS[1], ttl, tonumber(ARGV[1]), tonumber(ARGV[2]))
+return {1, ttl, redis.call('HGETALL', KEYS[1])}
+`)
+
+func (s *RedisStore) Renew(ctx context.Context, key string, now, expiresAt time.Time) (shortener.Link, error) {
+	res, err := renewScript.Run(ctx, s.client, []string{s.key(key), s.metaKey(key)},
+		now.UnixMilli(), expiresAt.UnixMilli(), time.Now().UnixMilli(),
+	).Slice()
+	if errors.Is(err, redis.Nil) {
+		return shortener.Link{}, shortener.ErrNotFound
+	}
+	if err != nil {
+		return shortener.Link{}, err
+	}
+	if len(res) != 3 {
+		return shortener.Link{}, fmt.Errorf("unexpected renew reply: %v", res)
+	}
+	switch status, _ := res[0].(int64); status {
+	case 3:
+		return shortener.Link{}, shortener.ErrExpired
+	case 4:
+		return shortener.Link{}, shortener.ErrDisabled
+	}
+
+	link, _, err := readReply(res[1:])
+	return link, err
+}
+
+// deleteScript turns a link into a tombstone: the hash is flagged, its
+// version bumped so a concurrent Update starts over, and it is kept for
+// good. KEYS: link, meta. Returns 0 for a missing link.
+var deleteScript = redis.NewScript(upgradeLua + `
+upgrade(KEYS[1], KEYS[2])
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if redis.call('HGET', KEYS[1], 'disabled') == '1' then
+	return 1
+end
+redis.call('HSET', KEYS[1], 'disabled', 1)
+redis.call('HINCRBY', KEYS[1], 'version', 1)
+redis.call('PERSIST', KEYS[1])
+return 1
+`)
+
+// Delete runs deleteScript, then takes the link out of the search indexes,
+// reading its tags and creator back from the tombstone.
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	ok, err := deleteScript.Run(ctx, s.client, []string{s.key(key), s.metaKey(key)}).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return shortener.ErrNotFound
+	}
+
+	link, _, err := s.readLink(ctx, key)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.unindex(ctx, pipe, key, link)
+		return nil
+	})
+	return err
+}
+
+// Next increments the short code counter with INCR, so it is shared by
+// every instance using the same Redis.
+func (s *RedisStore) Next(ctx context.Context) (uint64, error) {
+	n, err := s.client.Incr(ctx, s.key(codeCounterKey)).Result()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(n), nil
+}
+
+// Iterate walks the keyspace with SCAN, in batches of scanCount keys, and
+// reads each batch with one pipelined round trip. Links still in the legacy
+// layout are upgraded as they are read. In cluster mode every master is
+// scanned.
+func (s *RedisStore) Iterate(ctx context.Context, fn func(key string, link shortener.Link) error) error {
+	var mu sync.Mutex
+	return s.scan(ctx, "", readScript, func(keys []string, replies []*redis.Cmd) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for i, key := range keys {
+			res, err := replies[i].Slice()
+			// Keys that expired since SCAN returned them are skipped.
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			link, _, err := readReply(res)
+			if err != nil {
+				return fmt.Errorf("failed to read link %q: %w", key, err)
+			}
+			if err := fn(key, link); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan walks the keyspace with SCAN and reads the links each call finds in
+// one pipelined round trip. The COUNT of each call is bounded by scanCount,
+// so a large keyspace never blocks Redis, and by the links still wanted for
+// the page, though SCAN may return a few more keys than asked for. The
+// cursor holds the index of the node being scanned, for cluster mode, and
+// its SCAN cursor; a cluster that changes shape between pages may have
+// links skipped or listed twice.
+func (s *RedisStore) Scan(ctx context.Context, cursor string, limit int) ([]shortener.LinkEntry, string, error) {
+	var node int
+	var position uint64
+	if cursor != "" {
+		if _, err := fmt.Sscanf(cursor, "%d:%d", &node, &position); err != nil || node < 0 {
+			return nil, "", shortener.InvalidFilterError{Reason: "malformed cursor"}
+		}
+	}
+	nodes, err := s.scanClients(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	match := escapeGlob(s.prefix) + "*"
+
+	var entries []shortener.LinkEntry
+	for node < len(nodes) && len(entries) < limit {
+		client := nodes[node]
+		count := int64(min(max(limit-len(entries), 1), scanCount))
+		keys, next, err := client.Scan(ctx, position, match, count).Result()
+		if err != nil {
+			return nil, "", err
+		}
+		keys = slices.DeleteFunc(keys, func(key string) bool {
+			return strings.Contains(strings.TrimPrefix(key, s.prefix), ":")
+		})
+
+		if len(keys) > 0 {
+			pipe := client.Pipeline()
+			replies := make([]*redis.Cmd, len(keys))
+			for i, key := range keys {
+				keys[i] = strings.TrimPrefix(key, s.prefix)
+				replies[i] = readScript.Eval(ctx, pipe, []string{key, s.metaKey(keys[i])})
+			}
+			if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+				return nil, "", err
+			}
+			for i, key := range keys {
+				res, err := replies[i].Slice()
+				// Keys that expired since SCAN returned them are skipped.
+				if errors.Is(err, redis.Nil) {
+					continue
+				}
+				if err != nil {
+					return nil, "", err
+				}
+				link, _, err := readReply(res)
+				if err != nil {
+					return nil, "", fmt.Errorf("failed to read link %q: %w", key, err)
+				}
+				if !link.Disabled {
+					entries = append(entries, shortener.LinkEntry{Key: key, Link: link})
+				}
+			}
+		}
+
+		position = next
+		if position == 0 {
+			node++
+		}
+	}
+
+	if node >= len(nodes) {
+		return entries, "", nil
+	}
+	return entries, fmt.Sprintf("%d:%d", node, position), nil
+}
+
+// scanClients returns the clients Scan walks in turn: the client itself,
+// or in cluster mode every master, ordered by address.
+func (s *RedisStore) scanClients(ctx context.Context) ([]redis.UniversalClient, error) {
+	cluster, ok := s.client.(*redis.ClusterClient)
+	if !ok {
+		return []redis.UniversalClient{s.client}, nil
+	}
+
+	var mu sync.Mutex
+	var masters []*redis.Client
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		mu.Lock()
+		masters = append(masters, node)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(masters, func(a, b *redis.Client) int {
+		return strings.Compare(a.Options().Addr, b.Options().Addr)
+	})
+
+	clients := make([]redis.UniversalClient, len(masters))
+	for i, master := range masters {
+		clients[i] = master
+	}
+	return clients, nil
+}
+
+// upgradeScript upgrades a link stored in the legacy layout. KEYS: link,
+// meta. Returns 1 if the link was upgraded.
+var upgradeScript = redis.NewScript(upgradeLua + `
+return upgrade(KEYS[1], KEYS[2])
+`)
+
+// UpgradeLinks rewrites every link still stored in the legacy layout as a
+// link hash, and returns how many it upgraded. Reads and writes upgrade
+// links as they touch them, so this is only needed to finish the migration
+// eagerly. It then adds every live link to the search indexes, which links
+// written before they existed are missing from.
+func (s *RedisStore) UpgradeLinks(ctx context.Context) (int, error) {
+	var mu sync.Mutex
+	var n int
+	err := s.scan(ctx, "string", upgradeScript, func(keys []string, replies []*redis.Cmd) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, reply := range replies {
+			upgraded, err := reply.Int()
+			if err != nil {
+				return err
+			}
+			n += upgraded
+		}
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+
+	pipe := s.client.Pipeline()
+	err = s.Iterate(ctx, func(key string, link shortener.Link) error {
+		if link.Disabled {
+			return nil
+		}
+		s.index(ctx, pipe, key, link, time.Duration(ttlMillis(link.ExpiresAt))*time.Millisecond)
+		if pipe.Len() < scanCount {
+			return nil
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return n, err
+	}
+	_, err = pipe.Exec(ctx)
+	return n, err
+}
+
+// Search reads the most selective index that applies to filter in code
+// order, a page of limit codes at a time, and checks each link against the
+// filter. Entries whose link is gone, deleted or no longer belongs in the
+// index are removed.
+func (s *RedisStore) Search(ctx context.Context, filter shortener.LinkFilter, after string, limit int) ([]shortener.LinkEntry, error) {
+	index, belongs := s.indexKey("", ""), func(shortener.Link) bool { return true }
+	switch {
+	case filter.Tag != "":
+		index, belongs = s.indexKey("tag", filter.Tag), func(link shortener.Link) bool { return slices.Contains(link.Tags, filter.Tag) }
+	case filter.Creator != "":
+		index, belongs = s.indexKey("creator", filter.Creator), func(link shortener.Link) bool { return link.Creator == filter.Creator }
+	case filter.Domain != "":
+		index, belongs = s.indexKey("domain", filter.Domain), func(link shortener.Link) bool { return link.Domain() == filter.Domain }
+	}
+
+	var entries []shortener.LinkEntry
+	start := "-"
+	if after != "" {
+		start = "(" + after
+	}
+	for len(entries) < limit {
+		codes, err := s.client.ZRangeArgs(ctx, redis.ZRangeArgs{
+			Key: index, Start: start, Stop: "+", ByLex: true, Count: int64(limit),
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(codes) == 0 {
+			break
+		}
+
+		pipe := s.client.Pipeline()
+		replies := make([]*redis.Cmd, len(codes))
+		for i, code := range codes {
+			replies[i] = readScript.Eval(ctx, pipe, []string{s.key(code), s.metaKey(code)})
+		}
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+
+		var stale []any
+		now := time.Now()
+		for i, code := range codes {
+			res, err := replies[i].Slice()
+			if errors.Is(err, redis.Nil) {
+				stale = append(stale, code)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			link, _, err := readReply(res)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read link %q: %w", code, err)
+			}
+			if link.Disabled || !belongs(link) {
+				stale = append(stale, code)
+				continue
+			}
+			if !expired(link, now) && filter.Matches(link) && len(entries) < limit {
+				entries = append(entries, shortener.LinkEntry{Key: code, Link: link})
+			}
+		}
+		if len(stale) > 0 {
+			if err := s.client.ZRem(ctx, index, stale...).Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(codes) < limit {
+			break
+		}
+		start = "(" + codes[len(codes)-1]
+	}
+	return entries, nil
+}
+
+// scan runs script on each link key of type keyType ("" for any) and passes
+// the replies to fn, one SCAN batch at a time. fn gets the short codes and
+// may be called concurrently for different cluster nodes.
+func (s *RedisStore) scan(ctx context.Context, keyType string, script *redis.Script, fn func(keys []string, replies []*redis.Cmd) error) error {
+	cluster, ok := s.client.(*redis.ClusterClient)
+	if !ok {
+		return s.scanNode(ctx, s.client, keyType, script, fn)
+	}
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		return s.scanNode(ctx, node, keyType, script, fn)
+	})
+}
+
+func (s *RedisStore) scanNode(ctx context.Context, client redis.UniversalClient, keyType string, script *redis.Script,
+	fn func(keys []string, replies []*redis.Cmd) error,
+) error {
+	if err := script.Load(ctx, client).Err(); err != nil {
+		return err
+	}
+	match := escapeGlob(s.prefix) + "*"
+
+	var cursor uint64
+	for {
+		keys, next, err := client.ScanType(ctx, cursor, match, scanCount, keyType).Result()
+		if err != nil {
+			return err
+		}
+
+		keys = slices.DeleteFunc(keys, func(key string) bool {
+			return strings.Contains(strings.TrimPrefix(key, s.prefix), ":")
+		})
+
+		if len(keys) > 0 {
+			pipe := client.Pipeline()
+			replies := make([]*redis.Cmd, len(keys))
+			for i, key := range keys {
+				keys[i] = strings.TrimPrefix(key, s.prefix)
+				replies[i] = script.EvalSha(ctx, pipe, []string{key, s.metaKey(keys[i])})
+			}
+			if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+			if err := fn(keys, replies); err != nil {
+				return err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// ttlExpiresAt converts a PTTL reply into an expiration time; -1 (no TTL)
+// maps to the zero time and -2 (missing key) to now.
+func ttlExpiresAt(ttl time.Duration) time.Time {
+	switch {
+	case ttl > 0:
+		return time.Now().Add(ttl)
+	case ttl == -2:
+		return time.Now()
+	default:
+		return time.Time{}
+	}
+}
+
+// unixMilli stores times in link hashes, with 0 for none.
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromUnixMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var (
+	redisPoolHitsDesc = prometheus.NewDesc("redis_pool_hits_total",
+		"Number of times a free connection was found in the pool", nil, nil)
+	redisPoolMissesDesc = prometheus.NewDesc("redis_pool_misses_total",
+		"Number of times a free connection was not found in the pool", nil, nil)
+	redisPoolTimeoutsDesc = prometheus.NewDesc("redis_pool_timeouts_total",
+		"Number of times a wait for a pool connection timed out", nil, nil)
+	redisPoolStaleDesc = prometheus.NewDesc("redis_pool_stale_connections_total",
+		"Number of stale connections removed from the pool", nil, nil)
+	redisPoolTotalDesc = prometheus.NewDesc("redis_pool_connections",
+		"Number of connections in the pool", nil, nil)
+	redisPoolIdleDesc = prometheus.NewDesc("redis_pool_idle_connections",
+		"Number of idle connections in the pool", nil, nil)
+)
+
+// Describe and Collect export the go-redis connection pool statistics, so a
+// RedisStore can be passed to prometheus.Register.
+func (s *RedisStore) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redisPoolHitsDesc
+	ch <- redisPoolMissesDesc
+	ch <- redisPoolTimeoutsDesc
+	ch <- redisPoolStaleDesc
+	ch <- redisPoolTotalDesc
+	ch <- redisPoolIdleDesc
+}
+
+func (s *RedisStore) Collect(ch chan<- prometheus.Metric) {
+	stats := s.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisPoolHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(redisPoolMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(redisPoolTimeoutsDesc, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(redisPoolStaleDesc, prometheus.CounterValue, float64(stats.StaleConns))
+	ch <- prometheus.MustNewConstMetric(redisPoolTotalDesc, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(redisPoolIdleDesc, prometheus.GaugeValue, float64(stats.IdleConns))
 }
 
 func (s *RedisStore) Close() error {
@@ -2,10 +2,14 @@ package storage
 
 import (
 	"context"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/enleur/shrink/internal/config"
+	"github.com/enleur/shrink/internal/shortener"
 	"github.com/enleur/shrink/tests"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,20 +23,252 @@ func TestRedisStore(t *testing.T) {
 		}
 	}()
 
-	store, err := NewRedisStore(redisAddress, 0)
+	store, err := NewRedisStore(config.RedisConfig{Addresses: []string{redisAddress}})
 	assert.NoError(t, err)
 
 	t.Run("Set and Get", func(t *testing.T) {
-		err := store.Set(ctx, "testKey", "testValue", time.Minute)
+		err := store.Set(ctx, "testKey", testLink("testValue", time.Minute))
 		assert.NoError(t, err)
 
-		value, err := store.Get(ctx, "testKey")
+		link, err := store.Get(ctx, "testKey")
 		assert.NoError(t, err)
-		assert.Equal(t, "testValue", value)
+		assert.Equal(t, "testValue", link.URL)
+	})
+
+	t.Run("Create Does Not Overwrite", func(t *testing.T) {
+		err := store.Create(ctx, "created", shortener.Link{URL: "first", ExpiresAt: time.Now().Add(time.Minute)})
+		assert.NoError(t, err)
+
+		err = store.Create(ctx, "created", shortener.Link{URL: "second", ExpiresAt: time.Now().Add(time.Minute)})
+		assert.ErrorIs(t, err, shortener.ErrAlreadyExists)
+
+		link, err := store.Get(ctx, "created")
+		assert.NoError(t, err)
+		assert.Equal(t, "first", link.URL)
+	})
+
+	t.Run("Get Expiry", func(t *testing.T) {
+		assert.NoError(t, store.Set(ctx, "expiring", testLink("value", time.Minute)))
+		assert.NoError(t, store.Set(ctx, "permanent", testLink("value", 0)))
+
+		link, err := store.Get(ctx, "expiring")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", link.URL)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), link.ExpiresAt, 5*time.Second)
+
+		link, err = store.Get(ctx, "permanent")
+		assert.NoError(t, err)
+		assert.True(t, link.ExpiresAt.IsZero())
+
+		_, err = store.Get(ctx, "nonExistentKey")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("Pool Metrics", func(t *testing.T) {
+		assert.Equal(t, 6, testutil.CollectAndCount(store))
+	})
+
+	t.Run("Iterate Skips Other Prefixes", func(t *testing.T) {
+		prefixed, err := NewRedisStore(config.RedisConfig{Addresses: []string{redisAddress}, KeyPrefix: "link:"})
+		assert.NoError(t, err)
+		defer func() { _ = prefixed.Close() }()
+
+		assert.NoError(t, prefixed.Set(ctx, "prefixed", testLink("https://example.com", time.Hour)))
+
+		var keys []string
+		err = prefixed.Iterate(ctx, func(key string, _ shortener.Link) error {
+			keys = append(keys, key)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"prefixed"}, keys)
+	})
+
+	t.Run("Counter Is Not A Link", func(t *testing.T) {
+		prefixed, err := NewRedisStore(config.RedisConfig{Addresses: []string{redisAddress}, KeyPrefix: "counted:"})
+		assert.NoError(t, err)
+		defer func() { _ = prefixed.Close() }()
+
+		first, err := prefixed.Next(ctx)
+		assert.NoError(t, err)
+		second, err := prefixed.Next(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, first+1, second)
+
+		assert.NoError(t, prefixed.Set(ctx, "counted", testLink("https://example.com", time.Hour)))
+
+		var keys []string
+		err = prefixed.Iterate(ctx, func(key string, _ shortener.Link) error {
+			keys = append(keys, key)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"counted"}, keys)
+	})
+
+	t.Run("Iterate", func(t *testing.T) {
+		assert.NoError(t, store.Set(ctx, "iterExpiring", testLink("https://example.com/a", time.Hour)))
+		assert.NoError(t, store.Set(ctx, "iterForever", testLink("https://example.com/b", 0)))
+
+		found := map[string]time.Time{}
+		err := store.Iterate(ctx, func(key string, link shortener.Link) error {
+			found[key] = link.ExpiresAt
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Contains(t, found, "iterExpiring")
+		assert.Contains(t, found, "iterForever")
+		assert.False(t, found["iterExpiring"].IsZero())
+		assert.True(t, found["iterForever"].IsZero())
+	})
+
+	t.Run("Find By URL", func(t *testing.T) {
+		assert.NoError(t, store.Create(ctx, "findMe", shortener.Link{URL: "https://example.com/find", ExpiresAt: time.Now().Add(time.Hour)}))
+
+		key, expiresAt, err := store.FindByURL(ctx, "https://example.com/find")
+		assert.NoError(t, err)
+		assert.Equal(t, "findMe", key)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 5*time.Second)
+
+		assert.NoError(t, store.Set(ctx, "findMe", testLink("https://example.com/moved", time.Hour)))
+		_, _, err = store.FindByURL(ctx, "https://example.com/find")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("Click Limit", func(t *testing.T) {
+		testClickLimit(t, store)
+	})
+
+	t.Run("Activation Window", func(t *testing.T) {
+		testActivationWindow(t, store)
+	})
+
+	t.Run("Record", func(t *testing.T) {
+		testRecord(t, store)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		testUpdate(t, store)
+	})
+
+	t.Run("Renew", func(t *testing.T) {
+		testRenew(t, store)
+	})
+
+	t.Run("Password", func(t *testing.T) {
+		testPassword(t, store)
+	})
+
+	t.Run("Redirect Status", func(t *testing.T) {
+		testRedirectStatus(t, store)
+	})
+
+	t.Run("Find By URLs", func(t *testing.T) {
+		testFindByURLs(t, store)
+	})
+
+	t.Run("Expires At Given Time", func(t *testing.T) {
+		testExpiresAtGivenTime(t, store)
+	})
+
+	t.Run("Create Batch", func(t *testing.T) {
+		testCreateBatch(t, store)
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		testSearch(t, store)
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		testScan(t, store)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		testDelete(t, store)
+	})
+
+	t.Run("Trims Indexes", func(t *testing.T) {
+		expiring := testLink("https://example.com/trim", time.Hour)
+		expiring.Tags, expiring.Creator = []string{"trim"}, "trimmer"
+		assert.NoError(t, store.Create(ctx, "trimExpiring", expiring))
+		permanent := testLink("https://example.com/trim", 0)
+		permanent.Tags = []string{"trim"}
+		assert.NoError(t, store.Create(ctx, "trimDeleted", permanent))
+
+		tagIndex := store.indexKey("tag", "trim")
+		assert.Equal(t, []string{"trimDeleted", "trimExpiring"}, store.client.ZRange(ctx, tagIndex, 0, -1).Val())
+
+		assert.NoError(t, store.Delete(ctx, "trimDeleted"))
+		assert.Equal(t, []string{"trimExpiring"}, store.client.ZRange(ctx, tagIndex, 0, -1).Val())
+		assert.Zero(t, store.client.ZScore(ctx, store.indexKey("", ""), "trimDeleted").Val())
+
+		later := time.Now().Add(2 * time.Hour)
+		for i := 0; store.client.ZCount(ctx, store.expiryKey(), "-inf", strconv.FormatInt(later.UnixMilli(), 10)).Val() > 0; i++ {
+			assert.Less(t, i, 10)
+			assert.NoError(t, store.trimIndexes(ctx, later))
+		}
+		assert.Empty(t, store.client.ZRange(ctx, tagIndex, 0, -1).Val())
+		assert.Empty(t, store.client.ZRange(ctx, store.indexKey("creator", "trimmer"), 0, -1).Val())
+		assert.Error(t, store.client.ZScore(ctx, store.indexKey("", ""), "trimExpiring").Err())
 	})
 
 	t.Run("Get Non-Existent Key", func(t *testing.T) {
 		_, err := store.Get(ctx, "nonExistentKey")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("Upgrades Legacy Links", func(t *testing.T) {
+		assert.NoError(t, store.client.Set(ctx, "legacy", "https://example.com/legacy", time.Hour).Err())
+		assert.NoError(t, store.client.HSet(ctx, store.metaKey("legacy"), "maxClicks", 3, "clicks", 1, "version", 2).Err())
+		assert.NoError(t, store.client.Set(ctx, "legacyIdle", "https://example.com/idle", 0).Err())
+
+		link, err := store.Resolve(ctx, "legacy", time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/legacy", link.URL)
+		assert.Equal(t, int64(3), link.MaxClicks)
+		assert.Equal(t, int64(2), link.Clicks)
+		assert.Equal(t, int64(2), link.Version)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), link.ExpiresAt, 5*time.Second)
+		assert.Equal(t, "hash", store.client.Type(ctx, "legacy").Val())
+		assert.Zero(t, store.client.Exists(ctx, store.metaKey("legacy")).Val())
+
+		n, err := store.UpgradeLinks(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, "hash", store.client.Type(ctx, "legacyIdle").Val())
+
+		link, err = store.Get(ctx, "legacyIdle")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/idle", link.URL)
+		assert.True(t, link.ExpiresAt.IsZero())
+	})
+
+	t.Run("Refuses Newer Schema", func(t *testing.T) {
+		assert.NoError(t, store.client.HSet(ctx, "future", "v", redisSchemaVersion+1, "url", "https://example.com").Err())
+
+		_, err := store.Get(ctx, "future")
 		assert.Error(t, err)
+		assert.NotErrorIs(t, err, shortener.ErrNotFound)
 	})
 }
+
+func TestNewRedisStoreConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		conf config.RedisConfig
+	}{
+		{name: "No Address", conf: config.RedisConfig{}},
+		{name: "Unknown Mode", conf: config.RedisConfig{Mode: "ring", Addresses: []string{"localhost:6379"}}},
+		{name: "Standalone With Several Addresses", conf: config.RedisConfig{Mode: RedisModeStandalone, Addresses: []string{"a:6379", "b:6379"}}},
+		{name: "Sentinel Without Master", conf: config.RedisConfig{Mode: RedisModeSentinel, Addresses: []string{"localhost:26379"}}},
+		{name: "Cluster With DB", conf: config.RedisConfig{Mode: RedisModeCluster, Addresses: []string{"localhost:7000"}, DB: 1}},
+		{name: "Missing CA File", conf: config.RedisConfig{Addresses: []string{"localhost:6379"}, TLS: config.RedisTLSConfig{Enabled: true, CAFile: "/nonexistent/ca.pem"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRedisStore(tt.conf)
+			assert.Error(t, err)
+		})
+	}
+}
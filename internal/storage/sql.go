@@ -0,0 +1,591 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/enleur/shrink/internal/shortener"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// SQLStore keeps links in a relational database. SQLite and Postgres are
+// supported; the schema is managed by Migrate.
+type SQLStore struct {
+	db      *sql.DB
+	dialect string
+}
+
+func NewSQLStore(driver, dsn string) (*SQLStore, error) {
+	var driverName string
+	switch driver {
+	case DriverSQLite:
+		driverName = "sqlite"
+		dsn = sqliteDSN(dsn)
+	case DriverPostgres:
+		driverName = "pgx"
+	default:
+		return nil, fmt.Errorf("unsupported sql driver %q", driver)
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if driver == DriverSQLite {
+		// SQLite allows a single writer; serializing access through one
+		// connection avoids "database is locked" errors and keeps
+		// in-memory databases shared across queries.
+		db.SetMaxOpenConns(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return &SQLStore{db: db, dialect: driver}, nil
+}
+
+// sqliteDSN makes the driver write timestamps in SQLite's own format, which
+// sorts correctly as text so expires_at can be compared in queries.
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_time_format=") {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_time_format=sqlite"
+}
+
+func (s *SQLStore) Set(ctx context.Context, key string, link shortener.Link) error {
+	history, err := encodeHistory(link.History)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO links (code, url, expires_at, slide_ms, max_expires_at, created_at, creator, max_clicks, clicks,
+			not_before, not_after, version, disabled, password_hash, history, title, description, tags, domain,
+			redirect_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+		ON CONFLICT (code) DO UPDATE SET url = excluded.url, expires_at = excluded.expires_at,
+			slide_ms = excluded.slide_ms, max_expires_at = excluded.max_expires_at,
+			created_at = excluded.created_at, creator = excluded.creator,
+			max_clicks = excluded.max_clicks, clicks = excluded.clicks,
+			not_before = excluded.not_before, not_after = excluded.not_after,
+			version = excluded.version, disabled = excluded.disabled,
+			password_hash = excluded.password_hash, history = excluded.history,
+			title = excluded.title, description = excluded.description, tags = excluded.tags,
+			domain = excluded.domain, redirect_status = excluded.redirect_status`,
+		key, link.URL, nullTime(link.ExpiresAt), link.Slide.Milliseconds(), nullTime(link.MaxExpiresAt),
+		nullTime(link.CreatedAt), link.Creator, link.MaxClicks, link.Clicks,
+		nullTime(link.NotBefore), nullTime(link.NotAfter), link.Version, link.Disabled, link.PasswordHash, history,
+		link.Title, link.Description, encodeTags(link.Tags), link.Domain(), link.RedirectStatus,
+	)
+	return err
+}
+
+// Create inserts the link unless the code is held by a live row. An expired
+// row is replaced in place.
+func (s *SQLStore) Create(ctx context.Context, key string, link shortener.Link) error {
+	return createLink(ctx, s.db, key, link)
+}
+
+// CreateBatch inserts every entry in one transaction. A taken code doesn't
+// fail the statement, so the other entries still go in.
+func (s *SQLStore) CreateBatch(ctx context.Context, entries []shortener.LinkEntry) ([]error, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	errs := make([]error, len(entries))
+	for i, entry := range entries {
+		err := createLink(ctx, tx, entry.Key, entry.Link)
+		if err != nil && !errors.Is(err, shortener.ErrAlreadyExists) {
+			return nil, err
+		}
+		errs[i] = err
+	}
+
+	return errs, tx.Commit()
+}
+
+// execer is a *sql.DB or *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func createLink(ctx context.Context, db execer, key string, link shortener.Link) error {
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO links (code, url, expires_at, slide_ms, max_expires_at, created_at, creator, max_clicks, clicks,
+			not_before, not_after, password_hash, title, description, tags, domain, redirect_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 0, $9, $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT (code) DO UPDATE SET url = excluded.url, expires_at = excluded.expires_at,
+			slide_ms = excluded.slide_ms, max_expires_at = excluded.max_expires_at,
+			created_at = excluded.created_at, creator = excluded.creator,
+			max_clicks = excluded.max_clicks, clicks = 0,
+			not_before = excluded.not_before, not_after = excluded.not_after,
+			password_hash = excluded.password_hash, version = 0, history = '',
+			title = excluded.title, description = excluded.description, tags = excluded.tags,
+			domain = excluded.domain, redirect_status = excluded.redirect_status
+		WHERE links.expires_at IS NOT NULL AND links.expires_at <= $17`,
+		key, link.URL, nullTime(link.ExpiresAt), link.Slide.Milliseconds(), nullTime(link.MaxExpiresAt),
+		nullTime(link.CreatedAt), link.Creator, link.MaxClicks,
+		nullTime(link.NotBefore), nullTime(link.NotAfter), link.PasswordHash,
+		link.Title, link.Description, encodeTags(link.Tags), link.Domain(), link.RedirectStatus, time.Now().UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return shortener.ErrAlreadyExists
+	}
+	return nil
+}
+
+// Next increments the short code counter row and returns its new value.
+func (s *SQLStore) Next(ctx context.Context) (uint64, error) {
+	var n int64
+	err := s.db.QueryRowContext(ctx,
+		`UPDATE counters SET value = value + 1 WHERE name = 'codes' RETURNING value`,
+	).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(n), nil
+}
+
+// shareableLinks matches the rows FindByURL may return, given the current
+// time as $1.
+const shareableLinks = `max_clicks = 0 AND not_before IS NULL AND not_after IS NULL AND slide_ms = 0
+	AND password_hash = '' AND redirect_status = 0 AND NOT disabled
+	AND (expires_at IS NULL OR expires_at > $1)`
+
+// FindByURL returns the code of a live link to url. If there are several,
+// the one that lives longest wins.
+func (s *SQLStore) FindByURL(ctx context.Context, url string) (string, time.Time, error) {
+	var code string
+	var expires sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`SELECT code, expires_at FROM links WHERE url = $2 AND `+shareableLinks+`
+		ORDER BY expires_at IS NULL DESC, expires_at DESC LIMIT 1`,
+		time.Now().UTC(), url,
+	).Scan(&code, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", time.Time{}, shortener.ErrNotFound
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return code, expires.Time, nil
+}
+
+// FindByURLs reads the live links to all the URLs in one query, and keeps
+// the one that lives longest for each, as FindByURL does.
+func (s *SQLStore) FindByURLs(ctx context.Context, urls []string) ([]shortener.URLMatch, error) {
+	matches := make([]shortener.URLMatch, len(urls))
+	for i := range matches {
+		matches[i].Err = shortener.ErrNotFound
+	}
+	if len(urls) == 0 {
+		return matches, nil
+	}
+
+	args := []any{time.Now().UTC()}
+	placeholders := make([]string, len(urls))
+	for i, url := range urls {
+		args = append(args, url)
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT url, code, expires_at FROM links WHERE url IN (`+strings.Join(placeholders, ", ")+`) AND `+shareableLinks+`
+		ORDER BY expires_at IS NULL DESC, expires_at DESC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	found := make(map[string]shortener.URLMatch)
+	for rows.Next() {
+		var url, code string
+		var expires sql.NullTime
+		if err := rows.Scan(&url, &code, &expires); err != nil {
+			return nil, err
+		}
+		if _, ok := found[url]; !ok {
+			found[url] = shortener.URLMatch{Key: code, ExpiresAt: expires.Time}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, url := range urls {
+		if match, ok := found[url]; ok {
+			matches[i] = match
+		}
+	}
+	return matches, nil
+}
+
+func (s *SQLStore) Get(ctx context.Context, key string) (shortener.Link, error) {
+	return scanLink(ctx, s.db, key)
+}
+
+// Resolve reads the link and, inside its activation window, counts the
+// click in the same transaction. The increment is conditional on the limit,
+// so concurrent redirects can't push clicks past max_clicks even without row
+// locks.
+func (s *SQLStore) Resolve(ctx context.Context, key string, now time.Time) (shortener.Link, error) {
+	return s.resolve(ctx, key, now, false)
+}
+
+func (s *SQLStore) Unlock(ctx context.Context, key string, now time.Time) (shortener.Link, error) {
+	return s.resolve(ctx, key, now, true)
+}
+
+// resolve follows the link at key; password-protected links are only
+// followed when unlock is set.
+func (s *SQLStore) resolve(ctx context.Context, key string, now time.Time, unlock bool) (shortener.Link, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return shortener.Link{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	link, err := selectLink(ctx, tx, key, now)
+	if err != nil {
+		return shortener.Link{}, err
+	}
+	if err := link.Active(now); err != nil {
+		return shortener.Link{}, err
+	}
+	if link.PasswordHash != "" && !unlock {
+		if link.MaxClicks > 0 && link.Clicks >= link.MaxClicks {
+			return shortener.Link{}, shortener.ErrExhausted
+		}
+		return shortener.Link{}, shortener.ErrPasswordRequired
+	}
+
+	if link.Slide > 0 {
+		link.ExpiresAt = link.Renewal(now.Add(link.Slide))
+	}
+	err = tx.QueryRowContext(ctx,
+		`UPDATE links SET clicks = clicks + 1, expires_at = CASE WHEN expires_at < $2 THEN $2 ELSE expires_at END
+		WHERE code = $1 AND (max_clicks = 0 OR clicks < max_clicks) RETURNING clicks`,
+		key, nullTime(link.ExpiresAt),
+	).Scan(&link.Clicks)
+	if errors.Is(err, sql.ErrNoRows) {
+		return shortener.Link{}, shortener.ErrExhausted
+	}
+	if err != nil {
+		return shortener.Link{}, err
+	}
+
+	return link, tx.Commit()
+}
+
+// Update only writes the row if its version is still the one fn saw, and
+// starts over otherwise.
+func (s *SQLStore) Update(ctx context.Context, key string, now time.Time, fn func(shortener.Link) (shortener.Link, error)) (shortener.Link, error) {
+	for {
+		current, err := selectLink(ctx, s.db, key, now)
+		if err != nil {
+			return shortener.Link{}, err
+		}
+		link, err := fn(current)
+		if err != nil {
+			return shortener.Link{}, err
+		}
+		history, err := encodeHistory(link.History)
+		if err != nil {
+			return shortener.Link{}, err
+		}
+
+		res, err := s.db.ExecContext(ctx,
+			`UPDATE links SET url = $1, domain = $2, expires_at = $3, redirect_status = $4, history = $5,
+				version = version + 1
+			WHERE code = $6 AND version = $7`,
+			link.URL, link.Domain(), nullTime(link.ExpiresAt), link.RedirectStatus, history, key, current.Version,
+		)
+		if err != nil {
+			return shortener.Link{}, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return shortener.Link{}, err
+		}
+		if n > 0 {
+			current.URL = link.URL
+			current.ExpiresAt = link.ExpiresAt
+			current.RedirectStatus = link.RedirectStatus
+			current.History = link.History
+			current.Version++
+			return current, nil
+		}
+	}
+}
+
+// Renew never moves expires_at back, so a concurrent renewal that went
+// further wins.
+func (s *SQLStore) Renew(ctx context.Context, key string, now, expiresAt time.Time) (shortener.Link, error) {
+	link, err := selectLink(ctx, s.db, key, now)
+	if err != nil {
+		return shortener.Link{}, err
+	}
+
+	link.ExpiresAt = link.Renewal(expiresAt)
+	_, err = s.db.ExecContext(ctx,
+		`UPDATE links SET expires_at = CASE WHEN expires_at < $2 THEN $2 ELSE expires_at END WHERE code = $1`,
+		key, nullTime(link.ExpiresAt),
+	)
+	if err != nil {
+		return shortener.Link{}, err
+	}
+	return link, nil
+}
+
+// Delete turns the row into a tombstone. Create only replaces expired rows,
+// and tombstones never expire.
+func (s *SQLStore) Delete(ctx context.Context, key string) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE links SET disabled = TRUE, expires_at = NULL, version = version + 1
+		WHERE code = $1 AND NOT disabled`,
+		key,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(ctx, `SELECT TRUE FROM links WHERE code = $1`, key).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return shortener.ErrNotFound
+	}
+	return err
+}
+
+// queryRower is a *sql.DB or *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// linkColumns are the columns scanRow reads, in order.
+const linkColumns = `url, expires_at, slide_ms, max_expires_at, created_at, creator, max_clicks, clicks,
+	not_before, not_after, version, disabled, password_hash, history, title, description, tags, redirect_status`
+
+// rowScanner is a *sql.Row or *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanRow reads a link selected as linkColumns, after any leading columns
+// scanned into dest.
+func scanRow(row rowScanner, dest ...any) (shortener.Link, error) {
+	var link shortener.Link
+	var expiresAt, maxExpiresAt, createdAt, notBefore, notAfter sql.NullTime
+	var slideMillis int64
+	var history, tags string
+	dest = append(dest, &link.URL, &expiresAt, &slideMillis, &maxExpiresAt, &createdAt, &link.Creator,
+		&link.MaxClicks, &link.Clicks, &notBefore, &notAfter, &link.Version, &link.Disabled, &link.PasswordHash,
+		&history, &link.Title, &link.Description, &tags, &link.RedirectStatus)
+	if err := row.Scan(dest...); err != nil {
+		return shortener.Link{}, err
+	}
+	link.ExpiresAt = expiresAt.Time
+	link.Slide = time.Duration(slideMillis) * time.Millisecond
+	link.MaxExpiresAt = maxExpiresAt.Time
+	link.CreatedAt = createdAt.Time
+	link.NotBefore = notBefore.Time
+	link.NotAfter = notAfter.Time
+	link.Tags = decodeTags(tags)
+	var err error
+	link.History, err = decodeHistory(history)
+	return link, err
+}
+
+// scanLink reads the link at key, whether it is live or not.
+func scanLink(ctx context.Context, q queryRower, key string) (shortener.Link, error) {
+	link, err := scanRow(q.QueryRowContext(ctx, `SELECT `+linkColumns+` FROM links WHERE code = $1`, key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return shortener.Link{}, shortener.ErrNotFound
+	}
+	return link, err
+}
+
+// selectLink reads the link at key, if it is live at time now.
+func selectLink(ctx context.Context, q queryRower, key string, now time.Time) (shortener.Link, error) {
+	link, err := scanLink(ctx, q, key)
+	if err != nil {
+		return shortener.Link{}, err
+	}
+	if expired(link, now) {
+		return shortener.Link{}, shortener.ErrExpired
+	}
+	if link.Disabled {
+		return shortener.Link{}, shortener.ErrDisabled
+	}
+	return link, nil
+}
+
+func (s *SQLStore) Iterate(ctx context.Context, fn func(key string, link shortener.Link) error) error {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT code, `+linkColumns+` FROM links
+		WHERE expires_at IS NULL OR expires_at > $1 ORDER BY code`,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var code string
+		link, err := scanRow(rows, &code)
+		if err != nil {
+			return err
+		}
+		if err := fn(code, link); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Search narrows the rows down with the indexed columns, then checks each
+// candidate against the filter, since rows written before the domain column
+// existed have it empty. Candidates are read a page at a time until limit
+// links match.
+func (s *SQLStore) Search(ctx context.Context, filter shortener.LinkFilter, after string, limit int) ([]shortener.LinkEntry, error) {
+	query := `SELECT code, ` + linkColumns + ` FROM links
+		WHERE NOT disabled AND (expires_at IS NULL OR expires_at > $1) AND code > $2`
+	args := []any{time.Now().UTC(), after}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.Tag != "" {
+		query += ` AND tags LIKE ` + arg("%,"+escapeLike(filter.Tag)+",%") + ` ESCAPE '\'`
+	}
+	if filter.Domain != "" {
+		query += ` AND domain IN (` + arg(filter.Domain) + `, '')`
+	}
+	if filter.Creator != "" {
+		query += ` AND creator = ` + arg(filter.Creator)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		query += ` AND created_at >= ` + arg(filter.CreatedFrom.UTC())
+	}
+	if !filter.CreatedTo.IsZero() {
+		query += ` AND created_at < ` + arg(filter.CreatedTo.UTC())
+	}
+	if filter.Text != "" {
+		pattern := arg("%" + escapeLike(filter.Text) + "%")
+		query += ` AND (LOWER(url) LIKE ` + pattern + ` ESCAPE '\' OR LOWER(title) LIKE ` + pattern +
+			` ESCAPE '\' OR LOWER(description) LIKE ` + pattern + ` ESCAPE '\')`
+	}
+	query += ` ORDER BY code LIMIT ` + arg(limit)
+
+	var entries []shortener.LinkEntry
+	for {
+		n := 0
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var code string
+			link, err := scanRow(rows, &code)
+			if err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			n++
+			args[1] = code
+			if filter.Matches(link) && len(entries) < limit {
+				entries = append(entries, shortener.LinkEntry{Key: code, Link: link})
+			}
+		}
+		if err := rows.Err(); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		if n < limit || len(entries) == limit {
+			return entries, nil
+		}
+	}
+}
+
+// Scan lists links in code order, the cursor being the last code listed.
+func (s *SQLStore) Scan(ctx context.Context, cursor string, limit int) ([]shortener.LinkEntry, string, error) {
+	entries, err := s.Search(ctx, shortener.LinkFilter{}, cursor, limit)
+	return entries, scanCursor(entries, limit), err
+}
+
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// encodeTags stores tags as ",a,b,", so each one is matched whole by
+// LIKE '%,tag,%'.
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "," + strings.Join(tags, ",") + ","
+}
+
+func decodeTags(s string) []string {
+	if s = strings.Trim(s, ","); s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// escapeLike escapes the LIKE wildcards in s, for patterns with
+// ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
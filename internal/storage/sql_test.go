@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/enleur/shrink/internal/shortener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewSQLStore(DriverSQLite, ":memory:")
+	require.NoError(t, err)
+	defer func() { _ = store.Close() }()
+
+	require.NoError(t, store.Migrate(ctx))
+
+	t.Run("Migrate Is Idempotent", func(t *testing.T) {
+		assert.NoError(t, store.Migrate(ctx))
+	})
+
+	t.Run("Set and Get", func(t *testing.T) {
+		err := store.Set(ctx, "testKey", testLink("testValue", time.Minute))
+		assert.NoError(t, err)
+
+		link, err := store.Get(ctx, "testKey")
+		assert.NoError(t, err)
+		assert.Equal(t, "testValue", link.URL)
+	})
+
+	t.Run("Set Overwrites", func(t *testing.T) {
+		assert.NoError(t, store.Set(ctx, "overwritten", testLink("first", time.Minute)))
+		assert.NoError(t, store.Set(ctx, "overwritten", testLink("second", time.Minute)))
+
+		link, err := store.Get(ctx, "overwritten")
+		assert.NoError(t, err)
+		assert.Equal(t, "second", link.URL)
+	})
+
+	t.Run("Create Does Not Overwrite", func(t *testing.T) {
+		err := store.Create(ctx, "created", shortener.Link{URL: "first", ExpiresAt: time.Now().Add(time.Minute)})
+		assert.NoError(t, err)
+
+		err = store.Create(ctx, "created", shortener.Link{URL: "second", ExpiresAt: time.Now().Add(time.Minute)})
+		assert.ErrorIs(t, err, shortener.ErrAlreadyExists)
+
+		link, err := store.Get(ctx, "created")
+		assert.NoError(t, err)
+		assert.Equal(t, "first", link.URL)
+	})
+
+	t.Run("Create Replaces Expired", func(t *testing.T) {
+		assert.NoError(t, store.Create(ctx, "recycled", shortener.Link{URL: "first", ExpiresAt: time.Now().Add(time.Millisecond)}))
+
+		time.Sleep(5 * time.Millisecond)
+
+		assert.NoError(t, store.Create(ctx, "recycled", shortener.Link{URL: "second", ExpiresAt: time.Now().Add(time.Minute)}))
+
+		link, err := store.Get(ctx, "recycled")
+		assert.NoError(t, err)
+		assert.Equal(t, "second", link.URL)
+	})
+
+	t.Run("Iterate", func(t *testing.T) {
+		assert.NoError(t, store.Set(ctx, "iterExpiring", testLink("https://example.com/a", time.Hour)))
+		assert.NoError(t, store.Set(ctx, "iterForever", testLink("https://example.com/b", 0)))
+
+		found := map[string]time.Time{}
+		err := store.Iterate(ctx, func(key string, link shortener.Link) error {
+			found[key] = link.ExpiresAt
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Contains(t, found, "iterExpiring")
+		assert.Contains(t, found, "iterForever")
+		assert.False(t, found["iterExpiring"].IsZero())
+		assert.True(t, found["iterForever"].IsZero())
+	})
+
+	t.Run("Find By URL", func(t *testing.T) {
+		assert.NoError(t, store.Create(ctx, "findMe", shortener.Link{URL: "https://example.com/find", ExpiresAt: time.Now().Add(time.Hour)}))
+
+		key, expiresAt, err := store.FindByURL(ctx, "https://example.com/find")
+		assert.NoError(t, err)
+		assert.Equal(t, "findMe", key)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, 5*time.Second)
+
+		assert.NoError(t, store.Set(ctx, "findMe", testLink("https://example.com/moved", time.Hour)))
+		_, _, err = store.FindByURL(ctx, "https://example.com/find")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+
+		assert.NoError(t, store.Set(ctx, "findBrief", testLink("https://example.com/brief", time.Millisecond)))
+		time.Sleep(5 * time.Millisecond)
+		_, _, err = store.FindByURL(ctx, "https://example.com/brief")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("Click Limit", func(t *testing.T) {
+		testClickLimit(t, store)
+	})
+
+	t.Run("Activation Window", func(t *testing.T) {
+		testActivationWindow(t, store)
+	})
+
+	t.Run("Record", func(t *testing.T) {
+		testRecord(t, store)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		testUpdate(t, store)
+	})
+
+	t.Run("Renew", func(t *testing.T) {
+		testRenew(t, store)
+	})
+
+	t.Run("Password", func(t *testing.T) {
+		testPassword(t, store)
+	})
+
+	t.Run("Redirect Status", func(t *testing.T) {
+		testRedirectStatus(t, store)
+	})
+
+	t.Run("Find By URLs", func(t *testing.T) {
+		testFindByURLs(t, store)
+	})
+
+	t.Run("Expires At Given Time", func(t *testing.T) {
+		testExpiresAtGivenTime(t, store)
+	})
+
+	t.Run("Create Batch", func(t *testing.T) {
+		testCreateBatch(t, store)
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		testSearch(t, store)
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		testScan(t, store)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		testDelete(t, store)
+	})
+
+	t.Run("Get Non-Existent Key", func(t *testing.T) {
+		_, err := store.Get(ctx, "nonExistentKey")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	})
+
+	t.Run("Expired Key", func(t *testing.T) {
+		err := store.Set(ctx, "shortLived", testLink("value", time.Millisecond))
+		assert.NoError(t, err)
+
+		time.Sleep(5 * time.Millisecond)
+
+		_, err = store.Resolve(ctx, "shortLived", time.Now())
+		assert.ErrorIs(t, err, shortener.ErrExpired)
+	})
+
+	t.Run("No Expiration", func(t *testing.T) {
+		err := store.Set(ctx, "permanent", testLink("value", 0))
+		assert.NoError(t, err)
+
+		link, err := store.Get(ctx, "permanent")
+		assert.NoError(t, err)
+		assert.Equal(t, "value", link.URL)
+	})
+
+	t.Run("Counter", func(t *testing.T) {
+		first, err := store.Next(ctx)
+		assert.NoError(t, err)
+		second, err := store.Next(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, first+1, second)
+	})
+}
@@ -0,0 +1,571 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/enleur/shrink/internal/shortener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLink returns a link to url that expires after ttl, or never for zero.
+func testLink(url string, ttl time.Duration) shortener.Link {
+	link := shortener.Link{URL: url}
+	if ttl > 0 {
+		link.ExpiresAt = time.Now().Add(ttl)
+	}
+	return link
+}
+
+// testClickLimit resolves a link limited to five clicks from many goroutines
+// at once and checks that exactly five redirects are served.
+func testClickLimit(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+	const maxClicks, parallel = 5, 50
+
+	require.NoError(t, store.Create(ctx, "limited", shortener.Link{URL: "https://example.com/secret", MaxClicks: maxClicks}))
+
+	var served, exhausted atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			link, err := store.Resolve(ctx, "limited", time.Now())
+			switch {
+			case err == nil:
+				served.Add(1)
+				assert.Equal(t, "https://example.com/secret", link.URL)
+			case errors.Is(err, shortener.ErrExhausted):
+				exhausted.Add(1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(maxClicks), served.Load())
+	assert.Equal(t, int64(parallel-maxClicks), exhausted.Load())
+
+	_, _, err := store.FindByURL(ctx, "https://example.com/secret")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+}
+
+// testActivationWindow checks that a one-click link only redirects inside its
+// window, and that requests outside it don't use up the click.
+func testActivationWindow(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+	start := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	end := start.Add(time.Hour)
+
+	require.NoError(t, store.Create(ctx, "windowed", shortener.Link{
+		URL:       "https://example.com/launch",
+		MaxClicks: 1,
+		NotBefore: start,
+		NotAfter:  end,
+	}))
+
+	_, err := store.Resolve(ctx, "windowed", start.Add(-time.Millisecond))
+	assert.ErrorIs(t, err, shortener.ErrNotYetActive)
+
+	link, err := store.Resolve(ctx, "windowed", start)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/launch", link.URL)
+	assert.True(t, start.Equal(link.NotBefore))
+	assert.True(t, end.Equal(link.NotAfter))
+	assert.Equal(t, int64(1), link.Clicks)
+
+	_, err = store.Resolve(ctx, "windowed", end)
+	assert.ErrorIs(t, err, shortener.ErrExpired)
+
+	_, _, err = store.FindByURL(ctx, "https://example.com/launch")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+}
+
+// testRecord checks that a link's details survive a round trip through the
+// store, and that redirects are counted while reads are not.
+func testRecord(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+	createdAt := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+
+	require.NoError(t, store.Create(ctx, "recorded", shortener.Link{
+		URL:       "https://example.com/recorded",
+		ExpiresAt: time.Now().Add(time.Hour),
+		CreatedAt: createdAt,
+		Creator:   "alice",
+	}))
+
+	for i := 0; i < 2; i++ {
+		_, err := store.Resolve(ctx, "recorded", time.Now())
+		require.NoError(t, err)
+	}
+
+	link, err := store.Get(ctx, "recorded")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/recorded", link.URL)
+	assert.True(t, createdAt.Equal(link.CreatedAt))
+	assert.Equal(t, "alice", link.Creator)
+	assert.Equal(t, int64(2), link.Clicks)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), link.ExpiresAt, 5*time.Second)
+
+	link.URL = "https://example.com/restored"
+	link.MaxClicks = 5
+	require.NoError(t, store.Set(ctx, "restored", link))
+
+	restored, err := store.Get(ctx, "restored")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/restored", restored.URL)
+	assert.True(t, createdAt.Equal(restored.CreatedAt))
+	assert.Equal(t, "alice", restored.Creator)
+	assert.Equal(t, int64(2), restored.Clicks)
+	assert.Equal(t, int64(5), restored.MaxClicks)
+}
+
+// testUpdate repoints a link without touching its expiration and checks the
+// URL index and the link's history follow it.
+func testUpdate(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	require.NoError(t, store.Create(ctx, "updated", shortener.Link{URL: "https://example.com/before", ExpiresAt: expiresAt}))
+
+	entry := shortener.HistoryEntry{
+		URL:       "https://example.com/before",
+		Actor:     "alice",
+		ChangedAt: time.Now().UTC().Truncate(time.Millisecond),
+		Reason:    "moved",
+	}
+	link, err := store.Update(ctx, "updated", time.Now(), func(current shortener.Link) (shortener.Link, error) {
+		assert.Equal(t, "https://example.com/before", current.URL)
+		assert.Empty(t, current.History)
+		current.URL = "https://example.com/after"
+		current.History = append(current.History, entry)
+		return current, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/after", link.URL)
+	assert.Equal(t, int64(1), link.Version)
+	assert.WithinDuration(t, expiresAt, link.ExpiresAt, 5*time.Second)
+
+	stored, err := store.Get(ctx, "updated")
+	require.NoError(t, err)
+	require.Len(t, stored.History, 1)
+	assert.Equal(t, entry.URL, stored.History[0].URL)
+	assert.Equal(t, entry.Actor, stored.History[0].Actor)
+	assert.Equal(t, entry.Reason, stored.History[0].Reason)
+	assert.True(t, entry.ChangedAt.Equal(stored.History[0].ChangedAt))
+
+	resolved, err := store.Resolve(ctx, "updated", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/after", resolved.URL)
+	assert.WithinDuration(t, expiresAt, resolved.ExpiresAt, 5*time.Second)
+
+	key, _, err := store.FindByURL(ctx, "https://example.com/after")
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", key)
+	_, _, err = store.FindByURL(ctx, "https://example.com/before")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+
+	refused := errors.New("refused")
+	_, err = store.Update(ctx, "updated", time.Now(), func(shortener.Link) (shortener.Link, error) {
+		return shortener.Link{}, refused
+	})
+	assert.ErrorIs(t, err, refused)
+
+	_, err = store.Update(ctx, "missingUpdate", time.Now(), func(current shortener.Link) (shortener.Link, error) {
+		return current, nil
+	})
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+}
+
+// testRenew checks that redirects renew a sliding link up to its ceiling,
+// and that explicit renewals never shorten a link.
+func testRenew(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, store.Create(ctx, "sliding", shortener.Link{
+		URL:          "https://example.com/sliding",
+		ExpiresAt:    now.Add(time.Hour),
+		Slide:        2 * time.Hour,
+		MaxExpiresAt: now.Add(3 * time.Hour),
+	}))
+
+	link, err := store.Resolve(ctx, "sliding", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, link.Slide)
+	assert.WithinDuration(t, now.Add(3*time.Hour), link.MaxExpiresAt, time.Second)
+	assert.WithinDuration(t, now.Add(2*time.Hour), link.ExpiresAt, 5*time.Second)
+
+	link, err = store.Renew(ctx, "sliding", now, now.Add(10*time.Hour))
+	require.NoError(t, err)
+	assert.WithinDuration(t, now.Add(3*time.Hour), link.ExpiresAt, 5*time.Second)
+
+	link, err = store.Renew(ctx, "sliding", now, now.Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.WithinDuration(t, now.Add(3*time.Hour), link.ExpiresAt, 5*time.Second)
+
+	link, err = store.Get(ctx, "sliding")
+	require.NoError(t, err)
+	assert.WithinDuration(t, now.Add(3*time.Hour), link.ExpiresAt, 5*time.Second)
+	assert.Equal(t, int64(0), link.Version)
+
+	_, _, err = store.FindByURL(ctx, "https://example.com/sliding")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+
+	require.NoError(t, store.Create(ctx, "fixed", shortener.Link{URL: "https://example.com/fixed", ExpiresAt: now.Add(time.Hour)}))
+	link, err = store.Resolve(ctx, "fixed", time.Now())
+	require.NoError(t, err)
+	assert.WithinDuration(t, now.Add(time.Hour), link.ExpiresAt, 5*time.Second)
+	link, err = store.Renew(ctx, "fixed", now, now.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.WithinDuration(t, now.Add(5*time.Hour), link.ExpiresAt, 5*time.Second)
+
+	require.NoError(t, store.Create(ctx, "forever", shortener.Link{URL: "https://example.com/forever"}))
+	link, err = store.Renew(ctx, "forever", now, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, link.ExpiresAt.IsZero())
+
+	_, err = store.Renew(ctx, "missingRenew", now, now.Add(time.Hour))
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+}
+
+// testCreateBatch checks that a batch creates what it can and reports the
+// entries whose key is taken, whether by an existing link or by an earlier
+// entry of the same batch.
+func testCreateBatch(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, store.Create(ctx, "batchTaken", shortener.Link{URL: "https://example.com/taken"}))
+
+	errs, err := store.CreateBatch(ctx, []shortener.LinkEntry{
+		{Key: "batchOne", Link: testLink("https://example.com/one", time.Hour)},
+		{Key: "batchTaken", Link: testLink("https://example.com/other", time.Hour)},
+		{Key: "batchTwo", Link: shortener.Link{URL: "https://example.com/two", MaxClicks: 3}},
+		{Key: "batchOne", Link: testLink("https://example.com/again", time.Hour)},
+	})
+	require.NoError(t, err)
+	require.Len(t, errs, 4)
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], shortener.ErrAlreadyExists)
+	assert.NoError(t, errs[2])
+	assert.ErrorIs(t, errs[3], shortener.ErrAlreadyExists)
+
+	link, err := store.Get(ctx, "batchOne")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/one", link.URL)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), link.ExpiresAt, 5*time.Second)
+	link, err = store.Get(ctx, "batchTwo")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), link.MaxClicks)
+	link, err = store.Get(ctx, "batchTaken")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/taken", link.URL)
+
+	key, _, err := store.FindByURL(ctx, "https://example.com/one")
+	assert.NoError(t, err)
+	assert.Equal(t, "batchOne", key)
+	_, _, err = store.FindByURL(ctx, "https://example.com/two")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+}
+
+// testSearch checks each filter on its own and together, paging in code
+// order, and that expired and deleted links are left out.
+func testSearch(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+	created := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	links := []shortener.LinkEntry{
+		{Key: "search1", Link: shortener.Link{
+			URL: "https://search.example/q3", Title: "Quarterly NEEDLE report", Tags: []string{"reports", "searchtest"},
+			Creator: "searcher", CreatedAt: created,
+		}},
+		{Key: "search2", Link: shortener.Link{
+			URL: "https://search.example:8443/docs", Description: "Docs", Tags: []string{"searchtest"},
+			Creator: "searcher", CreatedAt: created.Add(time.Hour), ExpiresAt: time.Now().Add(time.Hour),
+		}},
+		{Key: "search3", Link: shortener.Link{
+			URL: "https://other.example/needle", Tags: []string{"searchtest"},
+			Creator: "someone", CreatedAt: created.Add(2 * time.Hour), MaxClicks: 5,
+		}},
+		{Key: "search4", Link: shortener.Link{URL: "https://search.example/gone", Tags: []string{"searchtest"}, Creator: "searcher"}},
+		{Key: "search5", Link: shortener.Link{
+			URL: "https://search.example/old", Tags: []string{"searchtest"}, Creator: "searcher",
+			ExpiresAt: time.Now().Add(time.Millisecond),
+		}},
+		{Key: "search6", Link: shortener.Link{
+			URL: "https://hidden.example/needle", Title: "Private report", Tags: []string{"hidden"},
+			PasswordHash: "hash",
+		}},
+	}
+	for _, entry := range links {
+		require.NoError(t, store.Create(ctx, entry.Key, entry.Link))
+	}
+	require.NoError(t, store.Delete(ctx, "search4"))
+	time.Sleep(5 * time.Millisecond)
+
+	search := func(filter shortener.LinkFilter, after string, limit int) []string {
+		t.Helper()
+		entries, err := store.Search(ctx, filter, after, limit)
+		require.NoError(t, err)
+		keys := make([]string, len(entries))
+		for i, entry := range entries {
+			keys[i] = entry.Key
+		}
+		return keys
+	}
+
+	assert.Equal(t, []string{"search1", "search2", "search3"}, search(shortener.LinkFilter{Tag: "searchtest"}, "", 10))
+	assert.Equal(t, []string{"search1"}, search(shortener.LinkFilter{Tag: "reports"}, "", 10))
+	assert.Equal(t, []string{"search1", "search2"}, search(shortener.LinkFilter{Creator: "searcher"}, "", 10))
+	assert.Equal(t, []string{"search1", "search2"}, search(shortener.LinkFilter{Domain: "search.example"}, "", 10))
+	assert.Equal(t, []string{"search2", "search3"}, search(shortener.LinkFilter{
+		Tag: "searchtest", CreatedFrom: created.Add(time.Hour), CreatedTo: created.Add(3 * time.Hour),
+	}, "", 10))
+	assert.Equal(t, []string{"search1", "search3"}, search(shortener.LinkFilter{Tag: "searchtest", Text: "needle"}, "", 10))
+	assert.Equal(t, []string{"search2"}, search(shortener.LinkFilter{Creator: "searcher", Text: "docs"}, "", 10))
+	assert.Empty(t, search(shortener.LinkFilter{Tag: "searchtest", Text: "100%"}, "", 10))
+	// A protected link's destination is not searchable, only its labels.
+	assert.Empty(t, search(shortener.LinkFilter{Tag: "hidden", Text: "needle"}, "", 10))
+	assert.Equal(t, []string{"search6"}, search(shortener.LinkFilter{Tag: "hidden", Text: "report"}, "", 10))
+
+	assert.Equal(t, []string{"search1", "search2"}, search(shortener.LinkFilter{Tag: "searchtest"}, "", 2))
+	assert.Equal(t, []string{"search3"}, search(shortener.LinkFilter{Tag: "searchtest"}, "search2", 2))
+	assert.Empty(t, search(shortener.LinkFilter{Tag: "searchtest"}, "search3", 2))
+
+	link, err := store.Get(ctx, "search1")
+	require.NoError(t, err)
+	assert.Equal(t, "Quarterly NEEDLE report", link.Title)
+	assert.Equal(t, []string{"reports", "searchtest"}, link.Tags)
+	link, err = store.Get(ctx, "search2")
+	require.NoError(t, err)
+	assert.Equal(t, "Docs", link.Description)
+
+	// A link that moves to another domain leaves that domain's results.
+	_, err = store.Update(ctx, "search2", time.Now(), func(current shortener.Link) (shortener.Link, error) {
+		current.URL = "https://moved.example/docs"
+		return current, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"search1"}, search(shortener.LinkFilter{Domain: "search.example"}, "", 10))
+	assert.Equal(t, []string{"search2"}, search(shortener.LinkFilter{Domain: "moved.example"}, "", 10))
+}
+
+// testScan pages through the whole store a few links at a time and checks
+// that every live link turns up with its expiration, and tombstones don't.
+func testScan(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+
+	for _, key := range []string{"scan1", "scan2", "scan3", "scan4", "scan5"} {
+		require.NoError(t, store.Create(ctx, key, testLink("https://example.com/"+key, time.Hour)))
+	}
+	require.NoError(t, store.Create(ctx, "scanForever", shortener.Link{URL: "https://example.com/forever"}))
+	require.NoError(t, store.Delete(ctx, "scan5"))
+
+	seen := make(map[string]shortener.Link)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 1000, "scan doesn't end")
+		entries, next, err := store.Scan(ctx, cursor, 2)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			seen[entry.Key] = entry.Link
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	for _, key := range []string{"scan1", "scan2", "scan3", "scan4"} {
+		if assert.Contains(t, seen, key) {
+			assert.Equal(t, "https://example.com/"+key, seen[key].URL)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), seen[key].ExpiresAt, 5*time.Second)
+		}
+	}
+	if assert.Contains(t, seen, "scanForever") {
+		assert.True(t, seen["scanForever"].ExpiresAt.IsZero())
+	}
+	assert.NotContains(t, seen, "scan5")
+}
+
+// testPassword checks that a protected link is only followed through
+// Unlock, that refusing it doesn't count a click, and that it never joins
+// the URL index.
+func testPassword(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, store.Create(ctx, "protected", shortener.Link{
+		URL:          "https://example.com/private",
+		ExpiresAt:    time.Now().Add(time.Hour),
+		MaxClicks:    1,
+		PasswordHash: "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$a2V5",
+	}))
+
+	_, err := store.Resolve(ctx, "protected", time.Now())
+	assert.ErrorIs(t, err, shortener.ErrPasswordRequired)
+
+	link, err := store.Get(ctx, "protected")
+	require.NoError(t, err)
+	assert.Equal(t, "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$a2V5", link.PasswordHash)
+	assert.Equal(t, int64(0), link.Clicks)
+
+	link, err = store.Unlock(ctx, "protected", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/private", link.URL)
+	assert.Equal(t, int64(1), link.Clicks)
+
+	_, err = store.Resolve(ctx, "protected", time.Now())
+	assert.ErrorIs(t, err, shortener.ErrExhausted)
+	_, err = store.Unlock(ctx, "protected", time.Now())
+	assert.ErrorIs(t, err, shortener.ErrExhausted)
+
+	_, _, err = store.FindByURL(ctx, "https://example.com/private")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+
+	_, err = store.Unlock(ctx, "missingUnlock", time.Now())
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+}
+
+// testRedirectStatus checks that a link's redirect status is stored,
+// survives an update, and keeps the link out of the URL index.
+func testRedirectStatus(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	require.NoError(t, store.Create(ctx, "redirectPlain", shortener.Link{URL: "https://example.com/redirect", ExpiresAt: expiresAt}))
+	require.NoError(t, store.Create(ctx, "redirectMoved", shortener.Link{
+		URL:            "https://example.com/redirect",
+		ExpiresAt:      expiresAt,
+		RedirectStatus: http.StatusMovedPermanently,
+	}))
+
+	link, err := store.Resolve(ctx, "redirectMoved", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMovedPermanently, link.RedirectStatus)
+
+	key, _, err := store.FindByURL(ctx, "https://example.com/redirect")
+	require.NoError(t, err)
+	assert.Equal(t, "redirectPlain", key)
+
+	link, err = store.Update(ctx, "redirectPlain", time.Now(), func(current shortener.Link) (shortener.Link, error) {
+		assert.Zero(t, current.RedirectStatus)
+		current.RedirectStatus = http.StatusTemporaryRedirect
+		return current, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTemporaryRedirect, link.RedirectStatus)
+
+	link, err = store.Get(ctx, "redirectPlain")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTemporaryRedirect, link.RedirectStatus)
+	assert.WithinDuration(t, expiresAt, link.ExpiresAt, 5*time.Second)
+
+	_, _, err = store.FindByURL(ctx, "https://example.com/redirect")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+}
+
+// testFindByURLs checks that a batch lookup finds each URL as FindByURL
+// does, in order.
+func testFindByURLs(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+
+	require.NoError(t, store.Create(ctx, "findManyShort", shortener.Link{URL: "https://example.com/many", ExpiresAt: expiresAt}))
+	require.NoError(t, store.Create(ctx, "findManyLocked", shortener.Link{URL: "https://example.com/many-locked", PasswordHash: "hash"}))
+	require.NoError(t, store.Create(ctx, "findManyGone", shortener.Link{URL: "https://example.com/many-gone"}))
+	require.NoError(t, store.Delete(ctx, "findManyGone"))
+
+	matches, err := store.FindByURLs(ctx, []string{
+		"https://example.com/many-missing",
+		"https://example.com/many",
+		"https://example.com/many-locked",
+		"https://example.com/many-gone",
+		"https://example.com/many",
+	})
+	require.NoError(t, err)
+	require.Len(t, matches, 5)
+	for _, i := range []int{0, 2, 3} {
+		assert.ErrorIs(t, matches[i].Err, shortener.ErrNotFound, matches[i].Key)
+	}
+	for _, i := range []int{1, 4} {
+		require.NoError(t, matches[i].Err)
+		assert.Equal(t, "findManyShort", matches[i].Key)
+		assert.WithinDuration(t, expiresAt, matches[i].ExpiresAt, time.Second)
+	}
+
+	matches, err = store.FindByURLs(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+// testExpiresAtGivenTime checks that reads and writes judge expiration by
+// the time they are given rather than the wall clock, even while the store
+// still holds the link.
+func testExpiresAtGivenTime(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+	later := time.Now().Add(2 * time.Hour)
+
+	for _, key := range []string{"clockResolve", "clockUpdate", "clockRenew"} {
+		require.NoError(t, store.Create(ctx, key, shortener.Link{URL: "https://example.com/clock", ExpiresAt: time.Now().Add(time.Hour)}))
+	}
+
+	_, err := store.Resolve(ctx, "clockResolve", later)
+	assert.ErrorIs(t, err, shortener.ErrExpired)
+	_, err = store.Update(ctx, "clockUpdate", later, func(current shortener.Link) (shortener.Link, error) {
+		return current, nil
+	})
+	assert.ErrorIs(t, err, shortener.ErrExpired)
+	_, err = store.Renew(ctx, "clockRenew", later, later.Add(time.Hour))
+	assert.ErrorIs(t, err, shortener.ErrExpired)
+}
+
+// testDelete checks that a deleted link leaves a tombstone that is gone for
+// readers and can't be created again.
+func testDelete(t *testing.T, store shortener.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, store.Create(ctx, "deleted", shortener.Link{URL: "https://example.com/abuse", ExpiresAt: time.Now().Add(time.Hour)}))
+	require.NoError(t, store.Delete(ctx, "deleted"))
+	assert.NoError(t, store.Delete(ctx, "deleted"))
+
+	_, err := store.Resolve(ctx, "deleted", time.Now())
+	assert.ErrorIs(t, err, shortener.ErrDisabled)
+	link, err := store.Get(ctx, "deleted")
+	assert.NoError(t, err)
+	assert.True(t, link.Disabled)
+	assert.True(t, link.ExpiresAt.IsZero())
+	_, err = store.Update(ctx, "deleted", time.Now(), func(current shortener.Link) (shortener.Link, error) {
+		return current, nil
+	})
+	assert.ErrorIs(t, err, shortener.ErrDisabled)
+	_, err = store.Renew(ctx, "deleted", time.Now(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, shortener.ErrDisabled)
+	_, _, err = store.FindByURL(ctx, "https://example.com/abuse")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+
+	err = store.Create(ctx, "deleted", shortener.Link{URL: "https://example.com/again"})
+	assert.ErrorIs(t, err, shortener.ErrAlreadyExists)
+
+	assert.ErrorIs(t, store.Delete(ctx, "missingDelete"), shortener.ErrNotFound)
+
+	if it, ok := store.(shortener.Iterator); ok {
+		var iterated bool
+		err := it.Iterate(ctx, func(key string, link shortener.Link) error {
+			if key == "deleted" {
+				iterated = true
+				assert.True(t, link.Disabled)
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.True(t, iterated, "tombstones are iterated")
+	}
+}
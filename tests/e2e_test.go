@@ -13,6 +13,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/enleur/shrink/internal/api"
+	"github.com/enleur/shrink/internal/config"
 	"github.com/enleur/shrink/internal/shortener"
 	"github.com/enleur/shrink/internal/storage"
 )
@@ -27,7 +28,7 @@ func TestEndToEnd(t *testing.T) {
 		}
 	}()
 
-	store, err := storage.NewRedisStore(redisAddress, 0)
+	store, err := storage.NewRedisStore(config.RedisConfig{Addresses: []string{redisAddress}})
 	assert.NoError(t, err)
 
 	shortenerService := shortener.NewService(store)